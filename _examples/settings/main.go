@@ -14,7 +14,7 @@ to change its value.
 
 If you enter a new value, this is saved to info.plist/the configuration
 sheet via Config.Set(), and the workflow is run again by calling
-its "settings" External Trigger via Alfred.RunTrigger().
+its "settings" External Trigger via Alfred.RunTriggerWithVars().
 */
 package main
 
@@ -58,7 +58,9 @@ func runSet(key, value string) {
 		wf.FatalError(err)
 	}
 
-	if err := wf.Alfred.RunTrigger("settings", ""); err != nil {
+	// pass the changed setting to the trigger as variables
+	vars := map[string]string{"key": key, "value": value}
+	if err := wf.Alfred.RunTriggerWithVars("settings", "", vars); err != nil {
 		wf.FatalError(err)
 	}
 
@@ -139,12 +139,13 @@ func run() {
 
 	// Call self with "check" command if an update is due and a check
 	// job isn't already running.
-	if wf.UpdateCheckDue() && !wf.IsRunning(updateJobName) {
-		log.Println("Running update check in background...")
-
+	if wf.UpdateCheckDue() {
 		cmd := exec.Command(os.Args[0], "-check")
-		if err := wf.RunInBackground(updateJobName, cmd); err != nil {
+		started, err := wf.RunInBackgroundOrSkip(updateJobName, cmd)
+		if err != nil {
 			log.Printf("Error starting update check: %s", err)
+		} else if started {
+			log.Println("Running update check in background...")
 		}
 	}
 
@@ -155,8 +156,8 @@ func run() {
 		// to order the results based on your past usage.
 		wf.Configure(aw.SuppressUIDs(true))
 
-		// Notify user of update. As this item is invalid (Valid(false)),
-		// actioning it expands the query to the Autocomplete value.
+		// Notify user of update. Actioning this item expands the query
+		// to "workflow:update".
 		// "workflow:update" triggers the updater Magic Action that
 		// is automatically registered when you configure Workflow with
 		// an Updater.
@@ -165,15 +166,14 @@ func run() {
 		// of the workflow and asks Alfred to install it.
 		wf.NewItem("Update available!").
 			Subtitle("↩ to install").
-			Autocomplete("workflow:update").
-			Valid(false).
+			ExpandTo("workflow:update").
 			Icon(iconAvailable)
 	}
 
 	// Script Filter results
-	for _, name := range items {
-		wf.NewItem(name).
-			Arg(name).
+	for i, it := range wf.AddItems(items...) {
+		name := items[i]
+		it.Arg(name).
 			UID(name).
 			Valid(true).
 			Icon(aw.IconUser)
@@ -183,9 +183,8 @@ func run() {
 	// As with the update notification, this item triggers a Magic
 	// Action that deletes the cached list of releases.
 	wf.NewItem("Reset update status").
-		Autocomplete("workflow:delcache").
-		Icon(aw.IconTrash).
-		Valid(false)
+		ExpandTo("workflow:delcache").
+		Icon(aw.IconTrash)
 
 	// Filter results on user query if present
 	if query != "" {
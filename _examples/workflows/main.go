@@ -103,14 +103,13 @@ func run() {
 	// If the cache has expired, set Rerun (which tells Alfred to re-run the
 	// workflow), and start the background update process if it isn't already
 	// running.
-	if wf.Cache.Expired(cacheName, maxCacheAge) {
-		wf.Rerun(0.3)
-		if !wf.IsRunning("download") {
-			cmd := exec.Command(os.Args[0], "-download")
-			if err := wf.RunInBackground("download", cmd); err != nil {
-				wf.FatalError(err)
-			}
-		} else {
+	if wf.RerunUntilFresh(wf.Cache, cacheName, maxCacheAge, 0.3) {
+		cmd := exec.Command(os.Args[0], "-download")
+		started, err := wf.RunInBackgroundOrSkip("download", cmd)
+		if err != nil {
+			wf.FatalError(err)
+		}
+		if !started {
 			log.Printf("download job already running.")
 		}
 		// Cache is also "expired" if it doesn't exist. So if there are no
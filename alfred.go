@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/deanishe/awgo/util"
@@ -127,6 +128,15 @@ func (a *Alfred) ActionAsType(typ string, value ...string) error {
 // workflow whose trigger should be run.
 // If not specified, it defaults to the current workflow's.
 func (a *Alfred) RunTrigger(name, query string, bundleID ...string) error {
+	return a.RunTriggerWithVars(name, query, nil, bundleID...)
+}
+
+// RunTriggerWithVars runs an External Trigger in the given workflow,
+// passing vars to it as workflow variables. Query and vars may be empty.
+//
+// Like RunTrigger, it accepts one optional bundleID argument. If not
+// specified, it defaults to the current workflow's.
+func (a *Alfred) RunTriggerWithVars(name, query string, vars map[string]string, bundleID ...string) error {
 	bid, _ := a.Lookup(EnvVarBundleID)
 	if len(bundleID) > 0 {
 		bid = bundleID[0]
@@ -140,9 +150,38 @@ func (a *Alfred) RunTrigger(name, query string, bundleID ...string) error {
 		opts["withArgument"] = query
 	}
 
+	if len(vars) > 0 {
+		opts["withVariables"] = vars
+	}
+
 	return a.runScript(scriptTrigger, name, opts)
 }
 
+// RunTriggerWithInfo is like RunTriggerWithVars, but it also passes the
+// name of the trigger, query and names of vars in the variables
+// EnvVarTrigger, EnvVarTriggerArg and EnvVarTriggerVars, so the triggered
+// workflow can retrieve them with Workflow.Trigger(), Workflow.TriggerArg()
+// and Workflow.TriggerVars().
+//
+// Use it to call your own workflow's triggers: other workflows won't
+// expect these variables.
+func (a *Alfred) RunTriggerWithInfo(name, query string, vars map[string]string, bundleID ...string) error {
+	all := map[string]string{EnvVarTrigger: name}
+	if query != "" {
+		all[EnvVarTriggerArg] = query
+	}
+	if len(vars) > 0 {
+		keys := make([]string, 0, len(vars))
+		for k, v := range vars {
+			keys = append(keys, k)
+			all[k] = v
+		}
+		sort.Strings(keys)
+		all[EnvVarTriggerVars] = strings.Join(keys, ",")
+	}
+	return a.RunTriggerWithVars(name, query, all, bundleID...)
+}
+
 // ReloadWorkflow tells Alfred to reload a workflow from disk.
 //
 // It accepts one optional bundleID argument, which is the bundle ID of the
@@ -78,6 +78,18 @@ func TestAlfred(t *testing.T) {
 		assert.Nil(t, a.RunTrigger("test", "AwGo, yo!", "com.example.workflow"), "call 3rd-party trigger failed")
 		assert.Equal(t, x, a.lastScript, "run trigger in other workflow failed")
 	})
+	t.Run("run trigger with variables", func(t *testing.T) {
+		x := `Application("com.runningwithcrayons.Alfred").runTrigger("test", {"inWorkflow":"net.deanishe.awgo","withVariables":{"key":"API_KEY","value":"hunter2"}});`
+		vars := map[string]string{"key": "API_KEY", "value": "hunter2"}
+		assert.Nil(t, a.RunTriggerWithVars("test", "", vars), "call trigger with variables failed")
+		assert.Equal(t, x, a.lastScript, "run trigger with variables failed")
+	})
+	t.Run("run trigger with info", func(t *testing.T) {
+		x := `Application("com.runningwithcrayons.Alfred").runTrigger("test", {"inWorkflow":"net.deanishe.awgo","withArgument":"AwGo, yo!","withVariables":{"AW_TRIGGER":"test","AW_TRIGGER_ARG":"AwGo, yo!","AW_TRIGGER_VARS":"key","key":"API_KEY"}});`
+		vars := map[string]string{"key": "API_KEY"}
+		assert.Nil(t, a.RunTriggerWithInfo("test", "AwGo, yo!", vars), "call trigger with info failed")
+		assert.Equal(t, x, a.lastScript, "run trigger with info failed")
+	})
 
 	t.Run("set theme", func(t *testing.T) {
 		x := `Application("com.runningwithcrayons.Alfred").setTheme("Alfred Notepad");`
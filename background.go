@@ -59,6 +59,20 @@ func (wf *Workflow) RunInBackground(jobName string, cmd *exec.Cmd) error {
 	return wf.savePid(jobName, cmd.Process.Pid)
 }
 
+// RunInBackgroundOrSkip is like RunInBackground, but instead of returning
+// an ErrJobExists error if a job of the same name is already running, it
+// returns started=false and a nil error, so there's no need to check
+// IsRunning first.
+func (wf *Workflow) RunInBackgroundOrSkip(jobName string, cmd *exec.Cmd) (started bool, err error) {
+	if err = wf.RunInBackground(jobName, cmd); err != nil {
+		if IsJobExists(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
 // Kill stops a background job.
 func (wf *Workflow) Kill(jobName string) error {
 	pid, err := wf.getPid(jobName)
@@ -66,6 +66,27 @@ func TestWorkflow_RunInBackground(t *testing.T) {
 	})
 }
 
+// Second job of same name is skipped.
+func TestWorkflow_RunInBackgroundOrSkip(t *testing.T) {
+	t.Parallel()
+
+	withTestWf(func(wf *Workflow) {
+		jobName := "sleep"
+		started, err := wf.RunInBackgroundOrSkip(jobName, exec.Command("sleep", "5"))
+		require.Nil(t, err, "start job failed")
+		assert.True(t, started, "job not started")
+		defer func() { _ = wf.Kill(jobName) }()
+
+		started, err = wf.RunInBackgroundOrSkip(jobName, exec.Command("sleep", "5"))
+		assert.Nil(t, err, "duplicate job returned error")
+		assert.False(t, started, "duplicate job started")
+
+		started, err = wf.RunInBackgroundOrSkip("badJob", exec.Command("/does/not/exist"))
+		assert.NotNil(t, err, `run "/does/not/exist" succeeded`)
+		assert.False(t, started, "bad job started")
+	})
+}
+
 // invalid command fails
 func TestWorkflow_RunInBackground_badJob(t *testing.T) {
 	t.Parallel()
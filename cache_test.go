@@ -4,8 +4,12 @@
 package aw
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -68,60 +72,153 @@ func TestCache_Store(t *testing.T) {
 	})
 }
 
-// LoadOrStore API.
-func TestCache_LoadOrStore(t *testing.T) {
+// Oldest files are deleted until cache is under size limit.
+func TestCache_Prune(t *testing.T) {
 	t.Parallel()
 
 	withTempDir(func(dir string) {
-		var (
-			s            = "this is a test"
-			reloadCalled bool
-			c            = NewCache(dir)
-			n            = "test.txt"
-			maxAge       = time.Second
-		)
-
-		reload := func() ([]byte, error) {
-			reloadCalled = true
-			return []byte(s), nil
+		c := NewCache(dir)
+		util.MustExist(filepath.Join(dir, "_aw"))
+		require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "_aw", "big"), make([]byte, 1000), 0600))
+
+		// 5 x 100-byte files with increasingly recent mtimes
+		names := []string{"a", "b", "c", "d", "e"}
+		now := time.Now()
+		for i, name := range names {
+			require.Nil(t, c.Store(name, make([]byte, 100)), "store failed")
+			mtime := now.Add(time.Duration(i-len(names)) * time.Minute)
+			require.Nil(t, os.Chtimes(c.path(name), mtime, mtime), "chtimes failed")
 		}
 
-		loadOrStore := func(maxAge time.Duration) {
-			data, err := c.LoadOrStore(n, maxAge, reload)
-			require.Nil(t, err, "load/store cached data failed")
-			require.Equal(t, []byte(s), data, "unexpected cache data")
+		n, err := c.Prune(500)
+		require.Nil(t, err, "prune failed")
+		assert.Equal(t, 0, n, "files deleted from cache under limit")
+
+		n, err = c.Prune(250)
+		require.Nil(t, err, "prune failed")
+		assert.Equal(t, 3, n, "unexpected number of deleted files")
+		for _, name := range names[:3] {
+			assert.False(t, c.Exists(name), "old file %q not deleted", name)
+		}
+		for _, name := range names[3:] {
+			assert.True(t, c.Exists(name), "new file %q deleted", name)
 		}
+		assert.True(t, util.PathExists(filepath.Join(dir, "_aw", "big")), "_aw directory pruned")
 
-		t.Run("sanity check", func(t *testing.T) {
-			assert.False(t, util.PathExists(c.path(n)), "cache file already exists")
-		})
+		n, err = c.Prune(0)
+		require.Nil(t, err, "prune failed")
+		assert.Equal(t, 2, n, "unexpected number of deleted files")
+	})
 
-		t.Run("empty cache", func(t *testing.T) {
-			loadOrStore(maxAge)
-			assert.True(t, reloadCalled, "reload not called")
-			assert.False(t, c.Expired(n, maxAge), "cache expired")
-		})
+	_, err := Cache{Dir: "/does/not/exist"}.Prune(0)
+	assert.NotNil(t, err, "prune non-existent directory succeeded")
+}
 
-		t.Run("load cached data", func(t *testing.T) {
-			reloadCalled = false
-			loadOrStore(maxAge)
-			assert.False(t, reloadCalled, "reload called")
-		})
+// Size includes files in subdirectories.
+func TestCache_Size(t *testing.T) {
+	t.Parallel()
 
-		t.Run("reload on 0 maxAge", func(t *testing.T) {
-			reloadCalled = false
-			loadOrStore(0)
-			assert.False(t, reloadCalled, "reload called")
+	withTempDir(func(dir string) {
+		c := NewCache(dir)
+		n, err := c.Size()
+		require.Nil(t, err, "size of empty cache failed")
+		assert.Equal(t, int64(0), n, "empty cache has size")
+
+		util.MustExist(filepath.Join(dir, "_aw", "sub"))
+		require.Nil(t, ioutil.WriteFile(filepath.Join(dir, "_aw", "sub", "big"), make([]byte, 1000), 0600))
+		require.Nil(t, c.Store("a", make([]byte, 100)), "store failed")
+		require.Nil(t, c.Store("b", make([]byte, 23)), "store failed")
+
+		n, err = c.Size()
+		require.Nil(t, err, "size failed")
+		assert.Equal(t, int64(1123), n, "unexpected size")
+	})
+
+	_, err := Cache{Dir: "/does/not/exist"}.Size()
+	assert.NotNil(t, err, "size of non-existent directory succeeded")
+}
 
-			time.Sleep(time.Second)
-			assert.True(t, c.Expired(n, maxAge), "cache not expired")
+// Cache age is calculated by the (mockable) clock.
+func TestCache_Expired(t *testing.T) {
+	withFakeClock(func(clock *fakeClock) {
+		withTempDir(func(dir string) {
+			var (
+				c = NewCache(dir)
+				n = "test.txt"
+			)
+			require.Nil(t, c.Store(n, []byte("test")), "store failed")
+			assert.False(t, c.Expired(n, time.Hour), "new cache expired")
+
+			clock.Add(59 * time.Minute)
+			assert.False(t, c.Expired(n, time.Hour), "cache expired early")
+
+			clock.Add(2 * time.Minute)
+			assert.True(t, c.Expired(n, time.Hour), "cache not expired")
+
+			age, err := c.Age(n)
+			require.Nil(t, err, "get age failed")
+			assert.True(t, age > time.Hour, "unexpected age: %v", age)
 		})
+	})
+}
 
-		t.Run("reload", func(t *testing.T) {
-			// Reload data
-			reloadCalled = false
-			loadOrStore(maxAge)
-			assert.True(t, reloadCalled, "reload not called")
+// LoadOrStore API. Uses a fake clock, so can't run in parallel.
+func TestCache_LoadOrStore(t *testing.T) {
+	withFakeClock(func(clock *fakeClock) {
+		withTempDir(func(dir string) {
+			var (
+				s            = "this is a test"
+				reloadCalled bool
+				c            = NewCache(dir)
+				n            = "test.txt"
+				maxAge       = time.Second
+			)
+
+			reload := func() ([]byte, error) {
+				reloadCalled = true
+				return []byte(s), nil
+			}
+
+			loadOrStore := func(maxAge time.Duration) {
+				data, err := c.LoadOrStore(n, maxAge, reload)
+				require.Nil(t, err, "load/store cached data failed")
+				require.Equal(t, []byte(s), data, "unexpected cache data")
+			}
+
+			t.Run("sanity check", func(t *testing.T) {
+				assert.False(t, util.PathExists(c.path(n)), "cache file already exists")
+			})
+
+			t.Run("empty cache", func(t *testing.T) {
+				loadOrStore(maxAge)
+				assert.True(t, reloadCalled, "reload not called")
+				assert.False(t, c.Expired(n, maxAge), "cache expired")
+			})
+
+			t.Run("load cached data", func(t *testing.T) {
+				reloadCalled = false
+				loadOrStore(maxAge)
+				assert.False(t, reloadCalled, "reload called")
+			})
+
+			t.Run("reload on 0 maxAge", func(t *testing.T) {
+				reloadCalled = false
+				loadOrStore(0)
+				assert.False(t, reloadCalled, "reload called")
+
+				// Seed clock after the data were stored, so expiry doesn't
+				// depend on how long the test has taken so far.
+				clock.now = time.Now()
+				clock.Add(2 * time.Second)
+				assert.True(t, c.Expired(n, maxAge), "cache not expired")
+			})
+
+			t.Run("reload", func(t *testing.T) {
+				// Reload data
+				reloadCalled = false
+				loadOrStore(maxAge)
+				assert.True(t, reloadCalled, "reload not called")
+			})
 		})
 	})
 }
@@ -172,61 +269,157 @@ func TestCache_StoreJSON(t *testing.T) {
 	})
 }
 
-// TestLoadOrStoreJSON tests JSON serialisation.
-func TestCache_LoadOrStoreJSON(t *testing.T) {
+// Data are stored and loaded with gob
+func TestCache_StoreGob(t *testing.T) {
 	t.Parallel()
 
 	withTempDir(func(dir string) {
-		var (
-			n            = "test.json"
-			c            = NewCache(dir)
-			maxAge       = time.Second
-			reloadCalled bool
-			a            = &TestData{"one", "two"}
-			b            = &TestData{}
-		)
+		n := "test.gob"
+		c := NewCache(dir)
+		p := c.path(n)
 
-		reload := func() (interface{}, error) {
-			reloadCalled = true
-			return &TestData{"one", "two"}, nil
-		}
+		assert.Nil(t, c.StoreGob(n, nil), "clear cached data failed")
+		assert.NotNil(t, c.LoadGob(n, &[]TestData{}), "load non-existent data succeeded")
+
+		a := []TestData{{"one", "two"}, {"three", "four"}}
+		require.Nil(t, c.StoreGob(n, a), "cache data failed")
+		assert.True(t, util.PathExists(p), "cache does not exist")
+		assert.False(t, c.Expired(n, time.Minute), "cache expired")
+
+		var b []TestData
+		require.Nil(t, c.LoadGob(n, &b), "load data failed")
+		assert.Equal(t, a, b, "unexpected data")
+
+		// Wrong type
+		var x int
+		assert.NotNil(t, c.LoadGob(n, &x), "loaded into wrong type")
+
+		// Session
+		s := NewSession(dir, "SESSIONID")
+		require.Nil(t, s.StoreGob(n, a), "cache session data failed")
+		b = nil
+		require.Nil(t, s.LoadGob(n, &b), "load session data failed")
+		assert.Equal(t, a, b, "unexpected session data")
 
-		loadOrStore := func(maxAge time.Duration) {
-			require.Nil(t, c.LoadOrStoreJSON(n, maxAge, reload, b), "load/store failed")
-			require.Equal(t, a, b, "unexpected cache data")
+		assert.Nil(t, c.StoreGob(n, nil), "clear cached data failed")
+		assert.False(t, util.PathExists(p), "deleted data exist")
+	})
+}
+
+func benchmarkData() []TestData {
+	data := make([]TestData, 10000)
+	for i := range data {
+		data[i] = TestData{fmt.Sprintf("title %d", i), fmt.Sprintf("subtitle %d", i)}
+	}
+	return data
+}
+
+func BenchmarkCache_LoadJSON(b *testing.B) {
+	withTempDir(func(dir string) {
+		c := NewCache(dir)
+		panicOnErr(c.StoreJSON("bench.json", benchmarkData()))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var v []TestData
+			panicOnErr(c.LoadJSON("bench.json", &v))
 		}
+	})
+}
 
-		t.Run("sanity check", func(t *testing.T) {
-			require.False(t, util.PathExists(c.path(n)), "cache file already exists")
-		})
+func BenchmarkCache_LoadGob(b *testing.B) {
+	withTempDir(func(dir string) {
+		c := NewCache(dir)
+		panicOnErr(c.StoreGob("bench.gob", benchmarkData()))
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var v []TestData
+			panicOnErr(c.LoadGob("bench.gob", &v))
+		}
+	})
+}
 
-		t.Run("cache empty", func(t *testing.T) {
-			loadOrStore(maxAge)
-			assert.True(t, reloadCalled, "reload not called")
-			assert.False(t, c.Expired(n, maxAge), "cache expired")
-		})
+// Errors for corrupt JSON identify the file.
+func TestCache_LoadJSON_invalid(t *testing.T) {
+	t.Parallel()
 
-		t.Run("load cached data", func(t *testing.T) {
-			reloadCalled = false
-			loadOrStore(maxAge)
-			assert.False(t, reloadCalled, "reload called")
-		})
+	withTempDir(func(dir string) {
+		c := NewCache(dir)
+		require.Nil(t, c.Store("truncated.json", []byte(`{"A": "one", "B":`)), "store data failed")
+		require.Nil(t, c.Store("syntax.json", []byte(`{"A": "one" "B": "two"}`)), "store data failed")
+		require.Nil(t, c.Store("type.json", []byte(`{"A": 1}`)), "store data failed")
+
+		for _, name := range []string{"truncated.json", "syntax.json", "type.json"} {
+			err := c.LoadJSON(name, &TestData{})
+			require.NotNil(t, err, "invalid JSON loaded")
+			assert.Contains(t, err.Error(), c.path(name), "path not in error")
+		}
 
-		// Load with 0 maxAge
-		t.Run("load with maxAge=0", func(t *testing.T) {
-			reloadCalled = false
-			loadOrStore(0)
-			assert.False(t, reloadCalled, "reload was called")
+		err := c.LoadJSON("syntax.json", &TestData{})
+		assert.Contains(t, err.Error(), "offset 13", "offset not in error")
+		var serr *json.SyntaxError
+		assert.True(t, errors.As(err, &serr), "SyntaxError not wrapped")
+	})
+}
 
-			time.Sleep(time.Second)
-			assert.True(t, c.Expired(n, maxAge), "cache has not expired")
-		})
+// TestLoadOrStoreJSON tests JSON serialisation.
+// Uses a fake clock, so can't run in parallel.
+func TestCache_LoadOrStoreJSON(t *testing.T) {
+	withFakeClock(func(clock *fakeClock) {
+		withTempDir(func(dir string) {
+			var (
+				n            = "test.json"
+				c            = NewCache(dir)
+				maxAge       = time.Second
+				reloadCalled bool
+				a            = &TestData{"one", "two"}
+				b            = &TestData{}
+			)
+
+			reload := func() (interface{}, error) {
+				reloadCalled = true
+				return &TestData{"one", "two"}, nil
+			}
+
+			loadOrStore := func(maxAge time.Duration) {
+				require.Nil(t, c.LoadOrStoreJSON(n, maxAge, reload, b), "load/store failed")
+				require.Equal(t, a, b, "unexpected cache data")
+			}
+
+			t.Run("sanity check", func(t *testing.T) {
+				require.False(t, util.PathExists(c.path(n)), "cache file already exists")
+			})
+
+			t.Run("cache empty", func(t *testing.T) {
+				loadOrStore(maxAge)
+				assert.True(t, reloadCalled, "reload not called")
+				assert.False(t, c.Expired(n, maxAge), "cache expired")
+			})
+
+			t.Run("load cached data", func(t *testing.T) {
+				reloadCalled = false
+				loadOrStore(maxAge)
+				assert.False(t, reloadCalled, "reload called")
+			})
+
+			// Load with 0 maxAge
+			t.Run("load with maxAge=0", func(t *testing.T) {
+				reloadCalled = false
+				loadOrStore(0)
+				assert.False(t, reloadCalled, "reload was called")
+
+				// Seed clock after the data were stored, so expiry doesn't
+				// depend on how long the test has taken so far.
+				clock.now = time.Now()
+				clock.Add(2 * time.Second)
+				assert.True(t, c.Expired(n, maxAge), "cache has not expired")
+			})
 
-		// Reload data
-		t.Run("reload data", func(t *testing.T) {
-			reloadCalled = false
-			loadOrStore(maxAge)
-			assert.True(t, reloadCalled, "reload not called")
+			// Reload data
+			t.Run("reload data", func(t *testing.T) {
+				reloadCalled = false
+				loadOrStore(maxAge)
+				assert.True(t, reloadCalled, "reload not called")
+			})
 		})
 	})
 }
@@ -390,6 +583,32 @@ func TestSession_LoadJSON(t *testing.T) {
 	})
 }
 
+// Session IDs can be made predictable.
+func TestNewSessionID_fixed(t *testing.T) {
+	orig := genSessionID
+	defer func() { genSessionID = orig }()
+
+	assert.NotEqual(t, NewSessionID(), NewSessionID(), "session IDs are not random")
+
+	genSessionID = func() string { return "FIXED" }
+	assert.Equal(t, "FIXED", NewSessionID(), "unexpected session ID")
+
+	withTempDir(func(dir string) {
+		s := NewSession(dir, NewSessionID())
+		require.Nil(t, s.Store("test.txt", []byte("test")), "store failed")
+		assert.True(t, util.PathExists(filepath.Join(dir, sessionPrefix+".FIXED.test.txt")), "unexpected session filename")
+	})
+
+	withTestEnv(func(e MapEnv) {
+		withTempDir(func(dir string) {
+			e[EnvVarCacheDir] = dir
+			e[EnvVarDataDir] = dir
+			wf := NewFromEnv(e, SessionName("AW_TEST_NO_SESSION"))
+			assert.Equal(t, "FIXED", wf.SessionID(), "unexpected workflow session ID")
+		})
+	})
+}
+
 func TestSession_LoadOrStoreJSON(t *testing.T) {
 	withTempDir(func(dir string) {
 		var (
@@ -0,0 +1,88 @@
+// Copyright (c) 2018 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"sort"
+	"sync"
+)
+
+// ItemCollector gathers Items from multiple goroutines. Feedback (and
+// therefore Workflow.NewItem) isn't safe for concurrent use, so
+// workflows that fetch results in parallel should add them to an
+// ItemCollector instead, and call Flush to add them to the feedback once
+// all the goroutines have finished.
+//
+// Each Item should only be modified by the goroutine that added it, and
+// not at all after it has been flushed.
+type ItemCollector struct {
+	mu    sync.Mutex
+	items []collectedItem
+}
+
+// collectedItem is an Item and its sort key.
+type collectedItem struct {
+	key string
+	it  *Item
+}
+
+// NewItemCollector creates a new, empty ItemCollector.
+func NewItemCollector() *ItemCollector { return &ItemCollector{} }
+
+// AddItem creates a new Item with the given title and returns it.
+// Items are flushed in the order of their keys, so use keys that determine
+// the order you want them to appear in, e.g. "<source>/<index>". Items with
+// the same key are flushed in the order they were added, which isn't
+// deterministic if they were added by different goroutines.
+func (c *ItemCollector) AddItem(key, title string) *Item {
+	it := &Item{title: title, vars: map[string]string{}}
+	c.mu.Lock()
+	c.items = append(c.items, collectedItem{key, it})
+	c.mu.Unlock()
+	return it
+}
+
+// Len returns the number of Items collected since the last flush.
+func (c *ItemCollector) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.items)
+}
+
+// Flush adds the collected Items to Workflow's feedback, sorted by key,
+// and empties the collector. Items inherit Workflow's top-level variables
+// (unless they set their own value), UID suppression and DefaultValid
+// (unless Item.Valid was called), as if they had been created with
+// Workflow.NewItem. It returns the flushed Items.
+func (c *ItemCollector) Flush(wf *Workflow) []*Item {
+	c.mu.Lock()
+	collected := c.items
+	c.items = nil
+	c.mu.Unlock()
+
+	sort.SliceStable(collected, func(i, j int) bool {
+		return collected[i].key < collected[j].key
+	})
+
+	fb := wf.Feedback
+	items := make([]*Item, len(collected))
+	for i, ci := range collected {
+		it := ci.it
+		for k, v := range fb.vars {
+			if _, ok := it.vars[k]; !ok {
+				it.vars[k] = v
+			}
+		}
+		if !it.validSet {
+			it.valid = fb.DefaultValid
+		}
+		if fb.NoUIDs {
+			it.uid = nil
+			it.noUID = true
+		}
+		items[i] = it
+	}
+	fb.Items = append(fb.Items, items...)
+	return items
+}
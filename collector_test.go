@@ -0,0 +1,73 @@
+// Copyright (c) 2018 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Items added from multiple goroutines are flushed in key order.
+func TestItemCollector(t *testing.T) {
+	t.Parallel()
+
+	withTestWf(func(wf *Workflow) {
+		wf.Var("top", "level")
+		wf.NewItem("first")
+
+		var (
+			c  = NewItemCollector()
+			wg sync.WaitGroup
+			x  []string
+		)
+		for i := 0; i < 10; i++ {
+			for j := 0; j < 10; j++ {
+				x = append(x, fmt.Sprintf("%d/%d", i, j))
+			}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 9; j >= 0; j-- {
+					key := fmt.Sprintf("%d/%d", i, j)
+					c.AddItem(key, key).Var("key", key)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		assert.Equal(t, 100, c.Len(), "unexpected collected item count")
+		items := c.Flush(wf)
+		assert.Equal(t, 0, c.Len(), "collector not emptied")
+		assert.Equal(t, 100, len(items), "unexpected flushed item count")
+		assert.Equal(t, 101, len(wf.Feedback.Items), "unexpected feedback item count")
+		assert.Equal(t, "first", wf.Feedback.Items[0].title, "existing item moved")
+
+		var titles []string
+		for _, it := range items {
+			titles = append(titles, it.title)
+			assert.Equal(t, "level", it.Vars()["top"], "top-level var not inherited")
+			assert.Equal(t, it.title, it.Vars()["key"], "item var overwritten")
+		}
+		assert.Equal(t, x, titles, "unexpected order")
+	})
+}
+
+// Flushed Items are valid if DefaultValid is set, unless set invalid.
+func TestItemCollector_defaultValid(t *testing.T) {
+	t.Parallel()
+
+	withTestWf(func(wf *Workflow) {
+		wf.Configure(DefaultValid(true))
+		c := NewItemCollector()
+		valid := c.AddItem("1", "valid")
+		invalid := c.AddItem("2", "invalid").Invalid()
+		c.Flush(wf)
+
+		assert.True(t, valid.valid, "item not valid")
+		assert.False(t, invalid.valid, "invalid item is valid")
+	})
+}
@@ -6,10 +6,15 @@ package aw
 import (
 	"errors"
 	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/deanishe/awgo/util"
+	"github.com/deanishe/awgo/util/build"
 	"go.deanishe.net/env"
 )
 
@@ -49,6 +54,20 @@ const (
 	// Machine-specific hash. Machine preferences are stored in
 	// Alfred.alfredpreferences/local/<hash>
 	EnvVarLocalhash = "alfred_preferences_localhash"
+
+	// Bundle ID of the frontmost application. Only set by Hotkey
+	// triggers with the "Pass focused app as variable" option enabled.
+	EnvVarFocusedApp = "focusedapp"
+
+	// AwGo's own convention, not Alfred's: these variables are only set
+	// by Alfred.RunTriggerWithInfo. Alfred itself doesn't tell a workflow
+	// that it was run by an External Trigger.
+	EnvVarTrigger     = "AW_TRIGGER"      // Name of the External Trigger
+	EnvVarTriggerArg  = "AW_TRIGGER_ARG"  // Argument passed to the trigger
+	EnvVarTriggerVars = "AW_TRIGGER_VARS" // Comma-separated names of variables passed to the trigger
+
+	// Set by the user to override the workflow's prefix for magic actions.
+	EnvVarMagicPrefix = "aw_magic_prefix"
 )
 
 // mockable JS script runner
@@ -57,6 +76,15 @@ var runJS = func(script string) error {
 	return err
 }
 
+var (
+	// DoRetries is the number of times Config.Do retries a failed call to
+	// Alfred, which may fail transiently if Alfred is busy.
+	DoRetries = 0
+	// DoRetryDelay is how long Config.Do waits before retrying a failed
+	// call to Alfred. The delay is doubled for each subsequent retry.
+	DoRetryDelay = 100 * time.Millisecond
+)
+
 // Config loads workflow settings from Alfred's environment variables.
 //
 // The Get* methods read a variable from the environment, converting it to
@@ -76,8 +104,9 @@ var runJS = func(script string) error {
 // to info.plist.
 type Config struct {
 	Env
-	reader  env.Reader
-	scripts []string
+	reader    env.Reader
+	scripts   []string
+	infoPlist string // path to info.plist; found automatically if empty
 }
 
 // NewConfig creates a new Config from the environment.
@@ -89,7 +118,7 @@ func NewConfig(e ...Env) *Config {
 	if len(e) > 0 {
 		ev = e[0]
 	} else {
-		ev = env.System
+		ev = sysEnv{}
 	}
 	return &Config{
 		Env:     ev,
@@ -150,6 +179,112 @@ func (cfg *Config) GetBool(key string, fallback ...bool) bool {
 	return cfg.reader.GetBool(key, fallback...)
 }
 
+// GetEnum returns the value for envvar "key" if it is one of allowed.
+// Values are compared case-insensitively, and the matching element of
+// allowed is returned, so the result is always one of allowed or fallback.
+// If the variable is unset or empty, fallback is returned. If it has any
+// other value, a warning is logged and fallback is returned.
+func (cfg *Config) GetEnum(key string, allowed []string, fallback string) string {
+	v := cfg.Get(key)
+	if v == "" {
+		return fallback
+	}
+	for _, s := range allowed {
+		if strings.EqualFold(v, s) {
+			return s
+		}
+	}
+	log.Printf("[warning] invalid value for %s: %q (allowed: %s), using %q",
+		key, v, strings.Join(allowed, ", "), fallback)
+	return fallback
+}
+
+// GetStringMap returns all variables whose names start with prefix. The
+// prefix is removed from the keys of the returned map, so with prefix
+// "VAR_", the variables VAR_FOO and VAR_BAR are returned as FOO and BAR.
+func (cfg *Config) GetStringMap(prefix string) map[string]string {
+	m := map[string]string{}
+	for _, k := range cfg.Keys() {
+		if !strings.HasPrefix(k, prefix) || len(k) == len(prefix) {
+			continue
+		}
+		if v, ok := cfg.Lookup(k); ok {
+			m[k[len(prefix):]] = v
+		}
+	}
+	return m
+}
+
+// FocusedApp returns the bundle ID of the application that was frontmost
+// when the workflow was triggered. It is only available when the workflow
+// is run from a Hotkey trigger with its "focusedapp" variable option
+// enabled; otherwise it returns an empty string.
+//
+// Alfred doesn't provide the current selection as a variable: Hotkey
+// triggers with the argument "Selection in macOS" and Universal Actions
+// pass it as the workflow's query/argument instead.
+func (cfg *Config) FocusedApp() string { return cfg.Get(EnvVarFocusedApp) }
+
+// Variables returned by Config.AllSettings. Only variables whose names
+// start with one of settingsPrefixes (or are defined in info.plist) are
+// returned, and the values of those whose names contain one of
+// settingsRedacted (case-insensitive) are replaced with "[redacted]".
+var (
+	settingsPrefixes = []string{"alfred_", "AW_", EnvVarMagicPrefix}
+	settingsRedacted = []string{"password", "secret", "token", "apikey", "api_key"}
+)
+
+// AllSettings returns Alfred's, AwGo's and the workflow's own variables
+// from the environment, e.g. for diagnostics. The workflow's variables are
+// those defined in info.plist, which is found like ResetToDefault finds it.
+// The values of variables whose names suggest they are secret (e.g. contain
+// "token" or "password") are redacted, so the result is safe to log.
+func (cfg *Config) AllSettings() map[string]string {
+	defined := map[string]string{}
+	if info, err := cfg.readInfoPlist(); err != nil {
+		log.Printf("[warning] workflow variables: %v", err)
+	} else {
+		defined = info.Variables
+	}
+
+	m := map[string]string{}
+	for _, k := range cfg.Keys() {
+		if _, ok := defined[k]; !ok && !hasAnyPrefix(k, settingsPrefixes) {
+			continue
+		}
+		v, ok := cfg.Lookup(k)
+		if !ok {
+			continue
+		}
+		if isRedacted(k) {
+			v = "[redacted]"
+		}
+		m[k] = v
+	}
+	return m
+}
+
+// hasAnyPrefix returns true if s starts with any of prefixes.
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRedacted returns true if key contains any of settingsRedacted.
+func isRedacted(key string) bool {
+	key = strings.ToLower(key)
+	for _, s := range settingsRedacted {
+		if strings.Contains(key, strings.ToLower(s)) {
+			return true
+		}
+	}
+	return false
+}
+
 // Set saves a workflow variable to info.plist.
 //
 // It accepts one optional bundleID argument, which is the bundle ID of the
@@ -166,6 +301,32 @@ func (cfg *Config) Set(key, value string, export bool, bundleID ...string) *Conf
 	return cfg.addScript(scriptSetConfig, key, opts)
 }
 
+// SetInt saves an int workflow variable to info.plist.
+// It is otherwise identical to Set.
+func (cfg *Config) SetInt(key string, value int, export bool, bundleID ...string) *Config {
+	return cfg.Set(key, strconv.Itoa(value), export, bundleID...)
+}
+
+// SetFloat saves a float workflow variable to info.plist. The value is
+// formatted with the minimum precision necessary to represent it exactly.
+// It is otherwise identical to Set.
+func (cfg *Config) SetFloat(key string, value float64, export bool, bundleID ...string) *Config {
+	return cfg.Set(key, strconv.FormatFloat(value, 'f', -1, 64), export, bundleID...)
+}
+
+// SetBool saves a boolean workflow variable to info.plist as "true" or
+// "false". It is otherwise identical to Set.
+func (cfg *Config) SetBool(key string, value bool, export bool, bundleID ...string) *Config {
+	return cfg.Set(key, strconv.FormatBool(value), export, bundleID...)
+}
+
+// SetDuration saves a time.Duration workflow variable to info.plist in the
+// format understood by GetDuration, e.g. "5m0s". It is otherwise identical
+// to Set.
+func (cfg *Config) SetDuration(key string, value time.Duration, export bool, bundleID ...string) *Config {
+	return cfg.Set(key, value.String(), export, bundleID...)
+}
+
 // Unset removes a workflow variable from info.plist.
 //
 // It accepts one optional bundleID argument, which is the bundle ID of the
@@ -180,9 +341,52 @@ func (cfg *Config) Unset(key string, bundleID ...string) *Config {
 	return cfg.addScript(scriptRmConfig, key, opts)
 }
 
+// ResetToDefault restores a workflow variable to the default value
+// defined in the workflow's info.plist, or removes the variable if
+// info.plist doesn't define it. Like From, it calls Alfred immediately,
+// so any other accumulated actions are also run.
+//
+// info.plist is looked for in the working directory and its parents,
+// like Workflow.Dir.
+func (cfg *Config) ResetToDefault(key string) error {
+	info, err := cfg.readInfoPlist()
+	if err != nil {
+		return fmt.Errorf("read default of %q: %w", key, err)
+	}
+
+	value, ok := info.Variables[key]
+	if !ok {
+		return cfg.Unset(key).Do()
+	}
+	export := true
+	for _, k := range info.Unexported {
+		if k == key {
+			export = false
+			break
+		}
+	}
+	return cfg.Set(key, value, export).Do()
+}
+
+// readInfoPlist reads the workflow's info.plist. Unless Config.infoPlist
+// is set, info.plist is looked for in the working directory and its parents.
+func (cfg *Config) readInfoPlist() (*build.Info, error) {
+	path := cfg.infoPlist
+	if path == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(findWorkflowRoot(wd), "info.plist")
+	}
+	return build.ReadInfoPlist(path)
+}
+
 // Do calls Alfred and runs the accumulated actions.
 //
 // Returns an error if there are no commands to run, or if the call to Alfred fails.
+// If DoRetries is greater than zero, failed calls are retried that many
+// times, and the error from the last attempt is returned.
 // Succeed or fail, any accumulated scripts and errors are cleared when Do()
 // is called.
 func (cfg *Config) Do() error {
@@ -194,7 +398,21 @@ func (cfg *Config) Do() error {
 	// reset
 	cfg.scripts = []string{}
 
-	return runJS(script)
+	var (
+		err   error
+		delay = DoRetryDelay
+	)
+	for i := 0; i <= DoRetries; i++ {
+		if i > 0 {
+			log.Printf("[warning] call to Alfred failed (retrying in %v): %v", delay, err)
+			time.Sleep(delay)
+			delay *= 2
+		}
+		if err = runJS(script); err == nil {
+			return nil
+		}
+	}
+	return err
 }
 
 // Extract bundle ID from argument or default.
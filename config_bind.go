@@ -4,14 +4,138 @@
 package aw
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
 	"sort"
+	"strings"
+	"time"
 
 	"go.deanishe.net/env"
 )
 
 // To populates (tagged) struct v with values from the environment.
+//
+// In addition to the types supported by deanishe/go-env, fields of type
+// time.Time are populated from variables in RFC 3339 format, e.g.
+// "2006-01-02T15:04:05Z". Empty variables leave time.Time fields unchanged.
 func (cfg *Config) To(v interface{}) error {
-	return env.Bind(v, cfg)
+	if err := env.Bind(v, cfg); err != nil {
+		return err
+	}
+	return timeFields(v, func(key string, fv reflect.Value) error {
+		s, _ := cfg.Lookup(key)
+		if s == "" {
+			return nil
+		}
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		fv.Set(reflect.ValueOf(t))
+		return nil
+	})
+}
+
+// ToTag is like To, but the variable for a field without an `env` tag is
+// named by the field's tag called tag, e.g. "json" or "conf", so structs
+// already tagged for another format needn't also be tagged for env.
+// An `env` tag takes precedence over tag, which takes precedence over the
+// name derived from the field name. Options in tag, such as "omitempty",
+// are ignored, and fields tagged "-" are not set.
+func (cfg *Config) ToTag(v interface{}, tag string) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return cfg.To(v)
+	}
+	aliases := map[string]string{}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" || f.Tag.Get("env") != "" {
+			continue
+		}
+		name := strings.Split(f.Tag.Get(tag), ",")[0]
+		if name != "" {
+			// "-" is never a valid variable name, so such fields aren't set
+			aliases[env.EnvVarForField(f.Name)] = name
+		}
+	}
+	return NewConfig(aliasEnv{cfg.Env, aliases}).To(v)
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// timeFields calls fn with the variable name and value of each exported
+// time.Time field of struct v. Variable names are determined the same way
+// as by deanishe/go-env.
+func timeFields(v interface{}, fn func(key string, fv reflect.Value) error) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Ptr {
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		f := rt.Field(i)
+		if f.PkgPath != "" || f.Type != timeType {
+			continue
+		}
+		key := strings.Split(f.Tag.Get("env"), ",")[0]
+		if key == "-" {
+			continue
+		}
+		if key == "" {
+			key = env.EnvVarForField(f.Name)
+		}
+		if err := fn(key, rv.Field(i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ToAll is like To, but instead of stopping at the first variable that
+// can't be parsed, it populates every field it can and returns a
+// BindErrors listing all variables that failed.
+func (cfg *Config) ToAll(v interface{}) error {
+	err := cfg.To(v)
+	if err == nil || errors.Is(err, env.ErrNotStruct) {
+		return err
+	}
+
+	// bind variables one at a time to find all the invalid ones
+	keys := cfg.Keys()
+	sort.Strings(keys)
+	var errs BindErrors
+	for _, k := range keys {
+		value, _ := cfg.Lookup(k)
+		if err := NewConfig(MapEnv{k: value}).To(v); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", k, err))
+		}
+	}
+	if len(errs) == 0 {
+		return err
+	}
+	return errs
+}
+
+// BindErrors is returned by Config.ToAll. It contains an error for each
+// variable that couldn't be bound.
+type BindErrors []error
+
+// Error implements error.
+func (e BindErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d invalid variable(s): %s", len(e), strings.Join(msgs, "; "))
 }
 
 // From saves the fields of (tagged) struct v to the workflow's settings in Alfred.
@@ -20,15 +144,69 @@ func (cfg *Config) To(v interface{}) error {
 // to omit any fields set to zero values.
 //
 // https://godoc.org/go.deanishe.net/env#DumpOption
+//
+// Fields of type time.Time are saved in RFC 3339 format, and zero times
+// as empty strings.
 func (cfg *Config) From(v interface{}, opt ...env.DumpOption) error {
-	variables, err := env.Dump(v, opt...)
+	variables, err := dumpVariables(v, opt...)
 	if err != nil {
 		return err
 	}
+	return cfg.setMulti(variables, false)
+}
 
+// FromChanged is like From, but only saves fields whose values differ from
+// the current values of the corresponding variables, so Alfred isn't called
+// at all if nothing has changed. This is typically used to save a struct
+// populated with To after some of its fields have been modified.
+func (cfg *Config) FromChanged(v interface{}, opt ...env.DumpOption) error {
+	variables, err := dumpVariables(v, opt...)
+	if err != nil {
+		return err
+	}
+	for k, value := range variables {
+		if cur, ok := cfg.Lookup(k); ok && cur == value {
+			delete(variables, k)
+		}
+	}
+	if len(variables) == 0 {
+		return nil
+	}
 	return cfg.setMulti(variables, false)
 }
 
+// dumpVariables returns the variables From saves for struct v.
+func dumpVariables(v interface{}, opt ...env.DumpOption) (map[string]string, error) {
+	variables, err := env.Dump(v, opt...)
+	if err != nil {
+		return nil, err
+	}
+
+	ignoreZero := ignoresZeroValues(opt...)
+	_ = timeFields(v, func(key string, fv reflect.Value) error {
+		t := fv.Interface().(time.Time)
+		switch {
+		case !t.IsZero():
+			variables[key] = t.Format(time.RFC3339)
+		case !ignoreZero:
+			variables[key] = ""
+		}
+		return nil
+	})
+	return variables, nil
+}
+
+// ignoresZeroValues returns true if opts include env.IgnoreZeroValues.
+// The options are opaque, so they're tested on a struct with a zero field.
+func ignoresZeroValues(opt ...env.DumpOption) bool {
+	m, err := env.Dump(struct{ Zero int }{}, opt...)
+	if err != nil {
+		return false
+	}
+	_, ok := m[env.EnvVarForField("Zero")]
+	return !ok
+}
+
 // setMulti batches the saving of multiple variables.
 func (cfg *Config) setMulti(variables map[string]string, export bool) error {
 	// sort keys to make the output testable
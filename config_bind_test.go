@@ -47,7 +47,7 @@ var (
 	privTestInt      = 10
 	privTestFloat    = 6.6
 
-	privTestEnv = env.MapEnv{
+	privTestEnv = MapEnv{
 		"AWGO_TEST_NAME":     privTestName,
 		"AWGO_TEST_QUOTED":   privTestQuoted,
 		"AWGO_TEST_EMPTY":    privTestEmpty,
@@ -86,8 +86,8 @@ func (mj *mockJSRunner) Run(script string) error {
 }
 
 // Returns a test implementation of Env
-func bindTestEnv() env.MapEnv {
-	return env.MapEnv{
+func bindTestEnv() MapEnv {
+	return MapEnv{
 		"ID":           "not empty",
 		"HOST":         testHostname,
 		"ONLINE":       fmt.Sprintf("%v", testOnline),
@@ -118,6 +118,34 @@ func TestConfig_To(t *testing.T) {
 	assert.Equal(t, testPingAverage, h.PingAverage, "unexpected PingAverage")
 }
 
+// ToAll reports every invalid variable.
+func TestConfig_ToAll(t *testing.T) {
+	t.Parallel()
+
+	e := bindTestEnv()
+	e["PORT"] = "-1"
+	e["PING"] = "ten seconds"
+	cfg := NewConfig(e)
+
+	h := &testHost{}
+	err := cfg.ToAll(h)
+	require.NotNil(t, err, "invalid config bound")
+	errs, ok := err.(BindErrors)
+	require.True(t, ok, "unexpected error type: %T", err)
+	require.Equal(t, 2, len(errs), "unexpected error count")
+	assert.Contains(t, errs[0].Error(), "PING", "PING not reported")
+	assert.Contains(t, errs[1].Error(), "PORT", "PORT not reported")
+
+	// valid fields are still populated
+	assert.Equal(t, testHostname, h.Hostname, "unexpected Hostname")
+	assert.Equal(t, testScore, h.Score, "unexpected Score")
+
+	// valid config
+	h = &testHost{}
+	require.Nil(t, NewConfig(bindTestEnv()).ToAll(h), "valid config failed")
+	assert.Equal(t, testPort, h.Port, "unexpected Port")
+}
+
 // generated script
 func TestConfig_Do(t *testing.T) {
 	orig := runJS
@@ -125,7 +153,7 @@ func TestConfig_Do(t *testing.T) {
 	mj := &mockJSRunner{}
 	runJS = mj.Run
 
-	cfg := NewConfig(env.MapEnv{
+	cfg := NewConfig(MapEnv{
 		EnvVarAlfredVersion: "4.0.4",
 		EnvVarBundleID:      "net.deanishe.awgo",
 	})
@@ -173,7 +201,7 @@ func TestConfig_From_script(t *testing.T) {
 	mj := &mockJSRunner{}
 	runJS = mj.Run
 
-	cfg := NewConfig(env.MapEnv{
+	cfg := NewConfig(MapEnv{
 		EnvVarAlfredVersion: "4.0.4",
 		EnvVarBundleID:      "net.deanishe.awgo",
 	})
@@ -189,6 +217,90 @@ Application("com.runningwithcrayons.Alfred").setConfiguration("TEST_QUOTED", {"e
 	assert.Equal(t, x, mj.script, "bad script")
 }
 
+// FromChanged only saves fields that differ from the environment.
+func TestConfig_FromChanged(t *testing.T) {
+	orig := runJS
+	defer func() { runJS = orig }()
+	mj := &mockJSRunner{}
+	runJS = mj.Run
+
+	type settings struct {
+		Username string
+		Interval time.Duration
+		Force    bool
+	}
+
+	cfg := NewConfig(MapEnv{
+		EnvVarAlfredVersion: "4.0.4",
+		EnvVarBundleID:      "net.deanishe.awgo",
+		"USERNAME":          "dave",
+		"INTERVAL":          "5m0s",
+		"FORCE":             "false",
+	})
+
+	var s settings
+	require.Nil(t, cfg.To(&s), "cfg.To failed")
+
+	// no changes
+	require.Nil(t, cfg.FromChanged(s), "cfg.FromChanged failed")
+	assert.Equal(t, "", mj.script, "Alfred called")
+
+	s.Interval = 10 * time.Minute
+	require.Nil(t, cfg.FromChanged(s), "cfg.FromChanged failed")
+	x := fmt.Sprintf(`Application(%q).setConfiguration("INTERVAL", {"exportable":false,"inWorkflow":"net.deanishe.awgo","toValue":"10m0s"});`, scriptAppName())
+	assert.Equal(t, x, mj.script, "bad script")
+
+	assert.EqualError(t, cfg.FromChanged("string"), "not a struct", "dump accepted invalid target")
+}
+
+// time.Time fields are saved and loaded in RFC 3339 format.
+func TestConfig_time(t *testing.T) {
+	orig := runJS
+	defer func() { runJS = orig }()
+	mj := &mockJSRunner{}
+	runJS = mj.Run
+
+	type settings struct {
+		LastSync time.Time
+		Created  time.Time `env:"CREATED_AT"`
+		Ignored  time.Time `env:"-"`
+		Never    time.Time
+	}
+
+	var (
+		lastSync = time.Date(2020, 3, 14, 15, 9, 26, 0, time.UTC)
+		created  = time.Date(2019, 1, 2, 3, 4, 5, 0, time.FixedZone("CET", 3600))
+		src      = settings{LastSync: lastSync, Created: created, Ignored: lastSync}
+		cfg      = NewConfig(MapEnv{
+			EnvVarAlfredVersion: "4.0.4",
+			EnvVarBundleID:      "net.deanishe.awgo",
+		})
+	)
+
+	require.Nil(t, cfg.From(src, env.IgnoreZeroValues), "cfg.From failed")
+	x := fmt.Sprintf(`Application(%[1]q).setConfiguration("CREATED_AT", {"exportable":false,"inWorkflow":"net.deanishe.awgo","toValue":"2019-01-02T03:04:05+01:00"});
+Application(%[1]q).setConfiguration("LAST_SYNC", {"exportable":false,"inWorkflow":"net.deanishe.awgo","toValue":"2020-03-14T15:09:26Z"});`, scriptAppName())
+	assert.Equal(t, x, mj.script, "bad script")
+
+	// load values saved above
+	var dst settings
+	cfg = NewConfig(MapEnv{
+		"LAST_SYNC":  "2020-03-14T15:09:26Z",
+		"CREATED_AT": "2019-01-02T03:04:05+01:00",
+		"IGNORED":    "2020-03-14T15:09:26Z",
+		"NEVER":      "",
+	})
+	require.Nil(t, cfg.To(&dst), "cfg.To failed")
+	assert.True(t, lastSync.Equal(dst.LastSync), "unexpected LastSync: %v", dst.LastSync)
+	assert.True(t, created.Equal(dst.Created), "unexpected Created: %v", dst.Created)
+	assert.True(t, dst.Ignored.IsZero(), "ignored field set")
+	assert.True(t, dst.Never.IsZero(), "empty variable set time")
+
+	err := NewConfig(MapEnv{"LAST_SYNC": "yesterday"}).To(&dst)
+	assert.NotNil(t, err, "invalid time accepted")
+	assert.Contains(t, err.Error(), "LAST_SYNC", "variable not in error")
+}
+
 func TestConfig_From_invalid_source(t *testing.T) {
 	invalid := []interface{}{
 		"string",
@@ -204,6 +316,48 @@ func TestConfig_From_invalid_source(t *testing.T) {
 	}
 }
 
+// ToTag names variables after another struct tag.
+func TestConfig_ToTag(t *testing.T) {
+	t.Parallel()
+
+	type settings struct {
+		Username string        `json:"username"`
+		APIKey   string        `json:"api_key,omitempty"`
+		Interval time.Duration `json:"interval" env:"UPDATE_INTERVAL"`
+		Since    time.Time     `json:"since"`
+		Force    bool
+		Secret   string `json:"-"`
+	}
+
+	cfg := NewConfig(MapEnv{
+		"username":        "dave",
+		"USERNAME":        "wrong",
+		"api_key":         "hunter2",
+		"interval":        "1m",
+		"UPDATE_INTERVAL": "5m",
+		"since":           "2020-03-14T15:09:26Z",
+		"FORCE":           "true",
+		"SECRET":          "wrong",
+	})
+
+	var s settings
+	require.Nil(t, cfg.ToTag(&s, "json"), "cfg.ToTag failed")
+	assert.Equal(t, "dave", s.Username, "unexpected Username")
+	assert.Equal(t, "hunter2", s.APIKey, "unexpected APIKey")
+	assert.Equal(t, 5*time.Minute, s.Interval, "env tag didn't take precedence")
+	assert.True(t, time.Date(2020, 3, 14, 15, 9, 26, 0, time.UTC).Equal(s.Since), "unexpected Since: %v", s.Since)
+	assert.True(t, s.Force, "unexpected Force")
+	assert.Equal(t, "", s.Secret, "ignored field set")
+
+	// Untagged struct behaves like To
+	s = settings{}
+	require.Nil(t, cfg.ToTag(&s, "conf"), "cfg.ToTag failed")
+	assert.Equal(t, "wrong", s.Username, "unexpected Username")
+	assert.Equal(t, "wrong", s.Secret, "unexpected Secret")
+
+	assert.NotNil(t, cfg.ToTag("string", "json"), "bound to non-struct")
+}
+
 // Populate a struct from workflow/environment variables. See EnvVarForField
 // for information on how fields are mapped to environment variables if
 // no variable name is specified using an `env:"name"` tag.
@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestConfigEnv verifies that Config holds the expected values.
@@ -57,6 +58,105 @@ func TestBundleID(t *testing.T) {
 	assert.Equal(t, x, cfg.getBundleID(x), "unexpected bundle ID")
 }
 
+// GetEnum only returns allowed values.
+func TestConfig_GetEnum(t *testing.T) {
+	t.Parallel()
+
+	var (
+		cfg     = NewConfig(MapEnv{"VIEW": "Grid", "BAD": "table", "EMPTY": ""})
+		allowed = []string{"list", "grid"}
+	)
+
+	tests := []struct {
+		key, x string
+	}{
+		{"VIEW", "grid"},    // valid, case-insensitive
+		{"BAD", "list"},     // invalid
+		{"EMPTY", "list"},   // empty
+		{"MISSING", "list"}, // unset
+	}
+
+	for _, td := range tests {
+		assert.Equal(t, td.x, cfg.GetEnum(td.key, allowed, "list"), "unexpected value for %s", td.key)
+	}
+}
+
+// GetStringMap returns prefixed variables with the prefix removed.
+func TestConfig_GetStringMap(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfig(MapEnv{
+		"VAR_FOO": "foo",
+		"VAR_BAR": "",
+		"VAR_":    "no name",
+		"VARIOUS": "not prefixed",
+		"OTHER":   "other",
+	})
+
+	x := map[string]string{"FOO": "foo", "BAR": ""}
+	assert.Equal(t, x, cfg.GetStringMap("VAR_"), "unexpected map")
+	assert.Equal(t, map[string]string{}, cfg.GetStringMap("NONE_"), "unexpected map")
+}
+
+// GetStringMap also works with the system environment.
+func TestConfig_GetStringMap_system(t *testing.T) {
+	_ = os.Setenv("AWGO_MAP_ONE", "1")
+	_ = os.Setenv("AWGO_MAP_TWO", "2")
+	defer unsetEnv("AWGO_MAP_ONE", "AWGO_MAP_TWO")
+
+	x := map[string]string{"ONE": "1", "TWO": "2"}
+	assert.Equal(t, x, NewConfig().GetStringMap("AWGO_MAP_"), "unexpected map")
+}
+
+func TestConfig_FocusedApp(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "", NewConfig(MapEnv{}).FocusedApp(), "unexpected focused app")
+	cfg := NewConfig(MapEnv{EnvVarFocusedApp: "com.apple.Safari"})
+	assert.Equal(t, "com.apple.Safari", cfg.FocusedApp(), "unexpected focused app")
+}
+
+// AllSettings returns only Alfred's, AwGo's and the workflow's variables and
+// redacts sensitive ones.
+func TestConfig_AllSettings(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfig(MapEnv{
+		EnvVarBundleID:       "net.deanishe.awgo",
+		EnvVarVersion:        "1.2.0",
+		EnvVarDebug:          "",
+		DefaultSessionName:   "test-session-id",
+		"AW_GITHUB_TOKEN":    "hunter2",
+		"alfred_db_PASSWORD": "hunter2",
+		"HOME":               "/Users/dave",
+		"API_KEY":            "hunter2",
+		EnvVarMagicPrefix:    "wf:",
+		"exported_var":       "value",
+		"unexported_var":     "other value",
+		"undefined_var":      "value",
+	})
+	cfg.infoPlist = "testdata/info.plist"
+
+	x := map[string]string{
+		EnvVarBundleID:       "net.deanishe.awgo",
+		EnvVarVersion:        "1.2.0",
+		EnvVarDebug:          "",
+		DefaultSessionName:   "test-session-id",
+		"AW_GITHUB_TOKEN":    "[redacted]",
+		"alfred_db_PASSWORD": "[redacted]",
+		EnvVarMagicPrefix:    "wf:",
+		"exported_var":       "value",
+		"unexported_var":     "other value",
+	}
+	assert.Equal(t, x, cfg.AllSettings(), "unexpected settings")
+
+	// workflow variables are omitted if info.plist can't be read
+	cfg.infoPlist = "testdata/does-not-exist.plist"
+	delete(x, "exported_var")
+	delete(x, "unexported_var")
+	assert.Equal(t, x, cfg.AllSettings(), "unexpected settings")
+}
+
 // Basic usage of Config.Get. Returns an empty string if variable is unset.
 func ExampleConfig_Get() {
 	// Set some test variables
@@ -230,8 +330,124 @@ func ExampleConfig_GetBool() {
 	)
 }
 
+// failingJSRunner fails the first n calls.
+type failingJSRunner struct {
+	n, calls int
+}
+
+func (r *failingJSRunner) Run(script string) error {
+	r.calls++
+	if r.calls <= r.n {
+		return fmt.Errorf("call %d failed", r.calls)
+	}
+	return nil
+}
+
+// Do retries failed calls to Alfred.
+func TestConfig_Do_retry(t *testing.T) {
+	orig, origRetries, origDelay := runJS, DoRetries, DoRetryDelay
+	defer func() { runJS, DoRetries, DoRetryDelay = orig, origRetries, origDelay }()
+	DoRetryDelay = time.Millisecond
+
+	cfg := NewConfig(MapEnv{
+		EnvVarAlfredVersion: "4.0.4",
+		EnvVarBundleID:      "net.deanishe.awgo",
+	})
+
+	// no retries
+	r := &failingJSRunner{n: 1}
+	runJS = r.Run
+	assert.NotNil(t, cfg.Set("TEST", "value", false).Do(), "failing call succeeded")
+	assert.Equal(t, 1, r.calls, "unexpected number of calls")
+
+	// fails once, then succeeds
+	DoRetries = 2
+	r = &failingJSRunner{n: 1}
+	runJS = r.Run
+	assert.Nil(t, cfg.Set("TEST", "value", false).Do(), "retry failed")
+	assert.Equal(t, 2, r.calls, "unexpected number of calls")
+
+	// all retries fail
+	r = &failingJSRunner{n: 5}
+	runJS = r.Run
+	err := cfg.Set("TEST", "value", false).Do()
+	assert.EqualError(t, err, "call 3 failed", "unexpected error")
+	assert.Equal(t, 3, r.calls, "unexpected number of calls")
+}
+
 func unsetEnv(keys ...string) {
 	for _, key := range keys {
 		panicOnErr(os.Unsetenv(key))
 	}
 }
+
+// Typed setters format values like Config.From.
+func TestConfig_SetTyped(t *testing.T) {
+	orig := runJS
+	defer func() { runJS = orig }()
+	mj := &mockJSRunner{}
+	runJS = mj.Run
+
+	cfg := NewConfig(MapEnv{
+		EnvVarAlfredVersion: "4.0.4",
+		EnvVarBundleID:      "net.deanishe.awgo",
+	})
+
+	tests := []struct {
+		name string
+		set  func() *Config
+		x    string
+	}{
+		{"SetInt", func() *Config { return cfg.SetInt("TEST", 10, false) }, "10"},
+		{"SetInt(negative)", func() *Config { return cfg.SetInt("TEST", -5, false) }, "-5"},
+		{"SetFloat", func() *Config { return cfg.SetFloat("TEST", 6.6, false) }, "6.6"},
+		{"SetFloat(whole)", func() *Config { return cfg.SetFloat("TEST", 3, false) }, "3"},
+		{"SetBool(true)", func() *Config { return cfg.SetBool("TEST", true, false) }, "true"},
+		{"SetBool(false)", func() *Config { return cfg.SetBool("TEST", false, false) }, "false"},
+		{"SetDuration", func() *Config { return cfg.SetDuration("TEST", 5*time.Minute, false) }, "5m0s"},
+	}
+
+	for _, td := range tests {
+		td := td // capture variable
+		t.Run(td.name, func(t *testing.T) {
+			x := fmt.Sprintf(`Application(%q).setConfiguration("TEST", {"exportable":false,"inWorkflow":"net.deanishe.awgo","toValue":%q});`,
+				scriptAppName(), td.x)
+			assert.Nil(t, td.set().Do(), "Do failed")
+			assert.Equal(t, x, mj.script, "unexpected script")
+		})
+	}
+}
+
+// ResetToDefault sets variables to their info.plist defaults.
+func TestConfig_ResetToDefault(t *testing.T) {
+	orig := runJS
+	defer func() { runJS = orig }()
+	mj := &mockJSRunner{}
+	runJS = mj.Run
+
+	cfg := NewConfig(MapEnv{
+		EnvVarAlfredVersion: "4.0.4",
+		EnvVarBundleID:      "net.deanishe.awgo",
+	})
+	cfg.infoPlist = "testdata/info.plist"
+
+	tests := []struct {
+		key, x string
+	}{
+		{"exported_var", fmt.Sprintf(`Application(%q).setConfiguration("exported_var", `+
+			`{"exportable":true,"inWorkflow":"net.deanishe.awgo","toValue":"exported_value"});`, scriptAppName())},
+		{"unexported_var", fmt.Sprintf(`Application(%q).setConfiguration("unexported_var", `+
+			`{"exportable":false,"inWorkflow":"net.deanishe.awgo","toValue":"unexported_value"});`, scriptAppName())},
+		{"no_default", fmt.Sprintf(`Application(%q).removeConfiguration("no_default", `+
+			`{"inWorkflow":"net.deanishe.awgo"});`, scriptAppName())},
+	}
+
+	for _, td := range tests {
+		mj.script = ""
+		require.Nil(t, cfg.ResetToDefault(td.key), "ResetToDefault failed")
+		assert.Equal(t, td.x, mj.script, "unexpected script")
+	}
+
+	cfg.infoPlist = "testdata/does-not-exist.plist"
+	assert.NotNil(t, cfg.ResetToDefault("exported_var"), "missing info.plist accepted")
+}
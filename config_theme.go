@@ -0,0 +1,103 @@
+// Copyright (c) 2019 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence applies http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// matches Alfred's theme colours, e.g. "rgba(255,255,255,1.00)"
+var rxRGBA = regexp.MustCompile(`^rgba\(\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d+)\s*,\s*(\d*\.?\d+)\s*\)$`)
+
+// RGBA is a colour with red, green and blue components (0-255) and
+// alpha (0.0-1.0).
+type RGBA struct {
+	R, G, B int
+	A       float64
+}
+
+// ThemeColors contains the colours of the user's Alfred theme.
+type ThemeColors struct {
+	Background          RGBA // Colour of Alfred's window
+	SelectionBackground RGBA // Colour of selected result
+}
+
+// Theme returns the colours of the user's Alfred theme, e.g. to render
+// icons or images that match it. It returns an error if
+// alfred_theme_background or alfred_theme_selection_background isn't
+// set or can't be parsed.
+func (cfg *Config) Theme() (ThemeColors, error) {
+	var (
+		tc  ThemeColors
+		err error
+	)
+	if tc.Background, err = parseColour(cfg.Get(EnvVarThemeBG)); err != nil {
+		return ThemeColors{}, fmt.Errorf("theme background: %w", err)
+	}
+	if tc.SelectionBackground, err = parseColour(cfg.Get(EnvVarThemeSelectionBG)); err != nil {
+		return ThemeColors{}, fmt.Errorf("theme selection background: %w", err)
+	}
+	return tc, nil
+}
+
+// ThemeBackground returns the background colour of the user's Alfred theme
+// as red, green and blue components (0-255) and alpha (0.0-1.0).
+// It returns an error if alfred_theme_background isn't set or can't be parsed.
+func (cfg *Config) ThemeBackground() (r, g, b int, a float64, err error) {
+	return parseRGBA(cfg.Get(EnvVarThemeBG))
+}
+
+// IsLightTheme returns true if the background colour of the user's Alfred
+// theme is light, e.g. to choose between dark and light icons. It returns
+// false if the colour can't be determined.
+func (cfg *Config) IsLightTheme() bool {
+	r, g, b, _, err := cfg.ThemeBackground()
+	if err != nil {
+		return false
+	}
+	return luma(r, g, b) > 0.5
+}
+
+// luma returns the perceived brightness (0.0-1.0) of an RGB colour.
+func luma(r, g, b int) float64 {
+	return (0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)) / 255
+}
+
+// parseColour parses a colour in Alfred's "rgba(r,g,b,a)" format into RGBA.
+func parseColour(s string) (RGBA, error) {
+	r, g, b, a, err := parseRGBA(s)
+	if err != nil {
+		return RGBA{}, err
+	}
+	return RGBA{r, g, b, a}, nil
+}
+
+// parseRGBA parses a colour in Alfred's "rgba(r,g,b,a)" format.
+func parseRGBA(s string) (r, g, b int, a float64, err error) {
+	m := rxRGBA.FindStringSubmatch(s)
+	if m == nil {
+		err = fmt.Errorf("invalid rgba colour: %q", s)
+		return
+	}
+
+	var rgb [3]int
+	for i, v := range m[1:4] {
+		n, _ := strconv.Atoi(v)
+		if n > 255 {
+			err = fmt.Errorf("invalid rgba colour: %q", s)
+			return
+		}
+		rgb[i] = n
+	}
+
+	if a, err = strconv.ParseFloat(m[4], 64); err != nil || a > 1 {
+		err = fmt.Errorf("invalid rgba colour: %q", s)
+		return
+	}
+
+	r, g, b = rgb[0], rgb[1], rgb[2]
+	return
+}
@@ -0,0 +1,75 @@
+// Copyright (c) 2019 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence applies http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// Theme colours are parsed and classified.
+func TestConfig_ThemeBackground(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in      string
+		r, g, b int
+		a       float64
+		light   bool
+		err     bool
+	}{
+		{tThemeBackground, 255, 255, 255, 1.0, true, false},
+		{"rgba(0,0,0,1.00)", 0, 0, 0, 1.0, false, false},
+		{"rgba(36, 39, 46, 0.95)", 36, 39, 46, 0.95, false, false},
+		{"rgba(236,236,236,.5)", 236, 236, 236, 0.5, true, false},
+		{"rgba(30,120,250,1)", 30, 120, 250, 1.0, false, false},
+		// invalid
+		{"", 0, 0, 0, 0, false, true},
+		{"rgb(255,255,255)", 0, 0, 0, 0, false, true},
+		{"rgba(256,255,255,1.0)", 0, 0, 0, 0, false, true},
+		{"rgba(255,255,255,1.5)", 0, 0, 0, 0, false, true},
+		{"rgba(255,255,1.0)", 0, 0, 0, 0, false, true},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.in, func(t *testing.T) {
+			t.Parallel()
+			cfg := NewConfig(MapEnv{EnvVarThemeBG: td.in})
+			r, g, b, a, err := cfg.ThemeBackground()
+			assert.Equal(t, td.light, cfg.IsLightTheme(), "unexpected IsLightTheme")
+			if td.err {
+				assert.NotNil(t, err, "invalid colour accepted")
+				return
+			}
+			assert.Nil(t, err, "parse colour failed")
+			assert.Equal(t, []int{td.r, td.g, td.b}, []int{r, g, b}, "unexpected RGB")
+			assert.Equal(t, td.a, a, "unexpected alpha")
+		})
+	}
+}
+
+// Theme colours are parsed from the environment.
+func TestConfig_Theme(t *testing.T) {
+	t.Parallel()
+
+	tc, err := NewConfig(testEnv).Theme()
+	assert.Nil(t, err, "parse theme failed")
+	assert.Equal(t, RGBA{255, 255, 255, 1.0}, tc.Background, "unexpected background")
+	assert.Equal(t, RGBA{255, 255, 255, 1.0}, tc.SelectionBackground, "unexpected selection background")
+
+	tc, err = NewConfig(MapEnv{
+		EnvVarThemeBG:          "rgba(36, 39, 46, 0.95)",
+		EnvVarThemeSelectionBG: "rgba(30,120,250,1)",
+	}).Theme()
+	assert.Nil(t, err, "parse theme failed")
+	assert.Equal(t, RGBA{36, 39, 46, 0.95}, tc.Background, "unexpected background")
+	assert.Equal(t, RGBA{30, 120, 250, 1.0}, tc.SelectionBackground, "unexpected selection background")
+
+	_, err = NewConfig(MapEnv{EnvVarThemeBG: "rgba(0,0,0,1)"}).Theme()
+	assert.NotNil(t, err, "missing selection background accepted")
+	_, err = NewConfig(MapEnv{EnvVarThemeSelectionBG: "rgba(0,0,0,1)"}).Theme()
+	assert.NotNil(t, err, "missing background accepted")
+}
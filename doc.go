@@ -160,7 +160,7 @@ read from environment variables set by Alfred.
 You can change defaults by passing one or more Options to New(). If
 you do not want to use Alfred's environment variables, or they aren't set
 (i.e. you're not running the code in Alfred), use NewFromEnv() with a custom
-Env implementation.
+Env implementation, such as MapEnv.
 
 A Workflow can be re-configured later using its Configure() method.
 
@@ -284,10 +284,10 @@ snippets of AppleScript/JavaScript code. See util for documentation and
 examples.
 
 AwGo offers a simple API to start/stop background processes via Workflow's
-RunInBackground(), IsRunning() and Kill() methods. This is useful for
-running checks for updates and other jobs that hit the network or take a
-significant amount of time to complete, allowing you to keep your Script
-Filters extremely responsive.
+RunInBackground(), RunInBackgroundOrSkip(), IsRunning() and Kill() methods.
+This is useful for running checks for updates and other jobs that hit the
+network or take a significant amount of time to complete, allowing you to
+keep your Script Filters extremely responsive.
 
 See _examples/update and _examples/workflows for demonstrations of this API.
 
@@ -4,7 +4,9 @@
 package aw
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"strings"
 )
@@ -29,6 +31,28 @@ type Env interface {
 	// boolean will be true, but the variable may still be an empty
 	// string.
 	Lookup(key string) (string, bool)
+
+	// Keys returns the names of all variables in the environment.
+	Keys() []string
+}
+
+// MapEnv is a map-based implementation of Env, e.g. for testing or
+// running a workflow outside Alfred via NewFromEnv().
+type MapEnv map[string]string
+
+// Lookup implements Env.
+func (e MapEnv) Lookup(key string) (string, bool) {
+	v, ok := e[key]
+	return v, ok
+}
+
+// Keys implements Env.
+func (e MapEnv) Keys() []string {
+	keys := make([]string, 0, len(e))
+	for k := range e {
+		keys = append(keys, k)
+	}
+	return keys
 }
 
 // sysEnv implements Env based on the real environment.
@@ -37,8 +61,121 @@ type sysEnv struct{}
 // Lookup wraps os.LookupEnv().
 func (e sysEnv) Lookup(key string) (string, bool) { return os.LookupEnv(key) }
 
-// Check that minimum required values are set.
-func validateEnv(env Env) error {
+// Keys returns the names of all variables in the environment.
+func (e sysEnv) Keys() []string {
+	var keys []string
+	for _, s := range os.Environ() {
+		if i := strings.Index(s, "="); i > 0 {
+			keys = append(keys, s[:i])
+		}
+	}
+	return keys
+}
+
+// NewJSONFileEnv returns an Env that reads default values from the JSON
+// object in file path. Variables in e take precedence over the file's
+// values, which fill in any variables missing from e.
+//
+// String values are used as-is, and other values are converted to their
+// JSON representation, so numbers, booleans and durations like "5m" can
+// be read with Config's typed methods or Config.To.
+func NewJSONFileEnv(path string, e Env) (Env, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("parse config file %q: %w", path, err)
+	}
+
+	defaults := MapEnv{}
+	for k, v := range values {
+		if s, ok := v.(string); ok {
+			defaults[k] = s
+			continue
+		}
+		js, err := json.Marshal(v)
+		if err != nil {
+			return nil, fmt.Errorf("convert %q in config file %q: %w", k, path, err)
+		}
+		defaults[k] = string(js)
+	}
+
+	return layeredEnv{e, defaults}, nil
+}
+
+// layeredEnv looks up variables in env first and defaults second.
+type layeredEnv struct {
+	env      Env
+	defaults Env
+}
+
+// Lookup implements Env.
+func (e layeredEnv) Lookup(key string) (string, bool) {
+	if v, ok := e.env.Lookup(key); ok {
+		return v, true
+	}
+	return e.defaults.Lookup(key)
+}
+
+// Keys implements Env.
+func (e layeredEnv) Keys() []string {
+	var (
+		keys = e.env.Keys()
+		seen = make(map[string]bool, len(keys))
+	)
+	for _, k := range keys {
+		seen[k] = true
+	}
+	for _, k := range e.defaults.Keys() {
+		if !seen[k] {
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
+
+// aliasEnv looks up the variables in aliases under their alias names.
+type aliasEnv struct {
+	env     Env
+	aliases map[string]string
+}
+
+// Lookup implements Env.
+func (e aliasEnv) Lookup(key string) (string, bool) {
+	if alias, ok := e.aliases[key]; ok {
+		key = alias
+	}
+	return e.env.Lookup(key)
+}
+
+// Keys implements Env.
+func (e aliasEnv) Keys() []string { return e.env.Keys() }
+
+// envHelp explains how to fix a missing variable.
+var envHelp = map[string]string{
+	EnvVarBundleID: "set a Bundle Id in the workflow's configuration sheet in Alfred Preferences",
+	EnvVarCacheDir: "Alfred only sets it for workflows with a Bundle Id; if you're running the workflow outside Alfred, set it or use CacheDirOption",
+	EnvVarDataDir:  "Alfred only sets it for workflows with a Bundle Id; if you're running the workflow outside Alfred, set it or use DataDirOption",
+}
+
+// EnvError is returned by Workflow.CheckEnv if the workflow's environment
+// is misconfigured. Issues contains a message for each problem that
+// explains how to fix it.
+type EnvError struct {
+	Issues []string
+}
+
+// Error implements error.
+func (e *EnvError) Error() string {
+	return "invalid Workflow environment: " + strings.Join(e.Issues, ", ")
+}
+
+// Check that minimum required values are set. Variables in ignore
+// are not required, e.g. because they have been set via an Option.
+func validateEnv(env Env, ignore ...string) error {
 	var (
 		issues   []string
 		required = []string{
@@ -48,16 +185,50 @@ func validateEnv(env Env) error {
 		}
 	)
 
+	skip := map[string]bool{}
+	for _, k := range ignore {
+		skip[k] = true
+	}
+
 	for _, k := range required {
+		if skip[k] {
+			continue
+		}
 		v, ok := env.Lookup(k)
 		if !ok || v == "" {
-			issues = append(issues, k+" is not set")
+			issues = append(issues, k+" is not set: "+envHelp[k])
 		}
 	}
 
 	if issues != nil {
-		return fmt.Errorf("invalid Workflow environment: %s", strings.Join(issues, ", "))
+		return &EnvError{issues}
 	}
 
 	return nil
 }
+
+// checkDirs returns an EnvError if any of dirs can't be created. If write
+// is true, it also checks that a file can be created in each directory.
+func checkDirs(write bool, dirs ...string) error {
+	var issues []string
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			issues = append(issues, fmt.Sprintf("can't create directory %q: %v", dir, err))
+			continue
+		}
+		if !write {
+			continue
+		}
+		f, err := ioutil.TempFile(dir, ".aw-check-")
+		if err != nil {
+			issues = append(issues, fmt.Sprintf("directory %q isn't writable: %v", dir, err))
+			continue
+		}
+		f.Close()
+		os.Remove(f.Name())
+	}
+	if issues != nil {
+		return &EnvError{issues}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2018 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sysEnv.Keys returns the names of the process's environment variables.
+func TestSysEnv_Keys(t *testing.T) {
+	_ = os.Setenv("AWGO_TEST_KEYS", "")
+	defer unsetEnv("AWGO_TEST_KEYS")
+
+	keys := sysEnv{}.Keys()
+	assert.Contains(t, keys, "AWGO_TEST_KEYS", "missing key")
+	assert.Equal(t, len(os.Environ()), len(keys), "unexpected key count")
+
+	unsetEnv("AWGO_TEST_KEYS")
+	assert.NotContains(t, sysEnv{}.Keys(), "AWGO_TEST_KEYS", "unset key returned")
+}
+
+// MapEnv.Keys returns the map's keys.
+func TestMapEnv_Keys(t *testing.T) {
+	t.Parallel()
+
+	e := MapEnv{"ONE": "1", "TWO": "", "THREE": "3"}
+	keys := e.Keys()
+	sort.Strings(keys)
+	assert.Equal(t, []string{"ONE", "THREE", "TWO"}, keys, "unexpected keys")
+	assert.Equal(t, []string{}, MapEnv{}.Keys(), "unexpected keys")
+}
+
+const testConfigJSON = `{
+	"HOST": "file.example.com",
+	"PORT": 3000,
+	"ONLINE": true,
+	"PING": "10s",
+	"SCORE": 5
+}`
+
+// Environment variables override values from file.
+func TestNewJSONFileEnv(t *testing.T) {
+	t.Parallel()
+
+	withTempDir(func(dir string) {
+		p := filepath.Join(dir, "config.json")
+		require.Nil(t, ioutil.WriteFile(p, []byte(testConfigJSON), 0600), "write config file")
+
+		e, err := NewJSONFileEnv(p, MapEnv{"HOST": "env.example.com", "SCORE": "10", "OTHER": ""})
+		require.Nil(t, err, "load config file")
+
+		keys := e.Keys()
+		sort.Strings(keys)
+		assert.Equal(t, []string{"HOST", "ONLINE", "OTHER", "PING", "PORT", "SCORE"}, keys, "unexpected keys")
+
+		cfg := NewConfig(e)
+		assert.Equal(t, "env.example.com", cfg.Get("HOST"), "env didn't override file")
+		assert.Equal(t, 10, cfg.GetInt("SCORE"), "env didn't override file")
+		assert.Equal(t, 3000, cfg.GetInt("PORT"), "file didn't fill gap")
+		assert.Equal(t, 10*time.Second, cfg.GetDuration("PING"), "file didn't fill gap")
+
+		h := &testHost{}
+		require.Nil(t, cfg.To(h), "bind config")
+		assert.Equal(t, "env.example.com", h.Hostname, "unexpected Hostname")
+		assert.Equal(t, uint(3000), h.Port, "unexpected Port")
+		assert.True(t, h.Online, "unexpected Online")
+
+		// invalid files
+		_, err = NewJSONFileEnv(filepath.Join(dir, "nonexistent.json"), MapEnv{})
+		assert.True(t, os.IsNotExist(err), "unexpected error: %v", err)
+		require.Nil(t, ioutil.WriteFile(p, []byte("[1, 2]"), 0600), "write config file")
+		_, err = NewJSONFileEnv(p, MapEnv{})
+		assert.NotNil(t, err, "accepted invalid config file")
+	})
+}
+
+// ConfigFile loads defaults from the data directory.
+func TestConfigFile(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		prev := wf.Configure(ConfigFile("nonexistent.json"))
+		assert.Equal(t, "", wf.Config.Get("HOST"), "unexpected HOST")
+		wf.Configure(prev)
+
+		p := filepath.Join(wf.DataDir(), "config.json")
+		require.Nil(t, ioutil.WriteFile(p, []byte(testConfigJSON), 0600), "write config file")
+
+		prev = wf.Configure(ConfigFile("config.json"))
+		assert.Equal(t, "file.example.com", wf.Config.Get("HOST"), "unexpected HOST")
+		assert.Equal(t, tBundleID, wf.Config.Get(EnvVarBundleID), "unexpected bundle ID")
+
+		wf.Configure(prev)
+		assert.Equal(t, "", wf.Config.Get("HOST"), "config not restored")
+	})
+}
+
+// Variables defined in info.plist are used as defaults.
+func TestInfoPlistDefaults(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		p := filepath.Join(wf.DataDir(), "info.plist")
+		require.Nil(t, ioutil.WriteFile(p, []byte(testInfoPlist), 0600), "write info.plist")
+
+		wf.Config.Env.(MapEnv)["OVERRIDDEN"] = "env"
+		prev := wf.Configure(InfoPlistDefaults(p))
+		assert.Equal(t, "plist", wf.Config.Get("PLIST_ONLY"), "unexpected PLIST_ONLY")
+		assert.Equal(t, "env", wf.Config.Get("OVERRIDDEN"), "unexpected OVERRIDDEN")
+		assert.Equal(t, tBundleID, wf.Config.Get(EnvVarBundleID), "unexpected bundle ID")
+
+		wf.Configure(prev)
+		assert.Equal(t, "", wf.Config.Get("PLIST_ONLY"), "config not restored")
+
+		prev = wf.Configure(InfoPlistDefaults(filepath.Join(wf.DataDir(), "nonexistent.plist")))
+		assert.Equal(t, "", wf.Config.Get("PLIST_ONLY"), "unexpected PLIST_ONLY")
+		wf.Configure(prev)
+	})
+}
+
+// InfoPlistDefaults reads the info.plist of each Workflow it's applied to.
+func TestInfoPlistDefaults_workflowDir(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		require.Nil(t, ioutil.WriteFile(filepath.Join(wf.DataDir(), "info.plist"),
+			[]byte(testInfoPlist), 0600), "write info.plist")
+
+		e := wf.Config.Env
+		opt := InfoPlistDefaults("")
+		wf.dir = "testdata"
+		wf.Configure(opt)
+		assert.Equal(t, "exported_value", wf.Config.Get("exported_var"), "unexpected exported_var")
+
+		wf2 := NewFromEnv(e)
+		wf2.dir = wf.DataDir()
+		wf2.Configure(opt)
+		assert.Equal(t, "plist", wf2.Config.Get("PLIST_ONLY"), "unexpected PLIST_ONLY")
+		assert.Equal(t, "", wf2.Config.Get("exported_var"), "read first workflow's info.plist")
+	})
+}
+
+const testInfoPlist = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>bundleid</key>
+	<string>net.deanishe.awgo</string>
+	<key>variables</key>
+	<dict>
+		<key>OVERRIDDEN</key>
+		<string>plist</string>
+		<key>PLIST_ONLY</key>
+		<string>plist</string>
+	</dict>
+</dict>
+</plist>
+`
@@ -4,12 +4,17 @@
 package aw
 
 import (
+	"crypto/sha1"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"reflect"
 	"sort"
 	"strings"
+	"unicode/utf8"
 
 	"go.deanishe.net/fuzzy"
 )
@@ -49,6 +54,7 @@ type Item struct {
 	autocomplete *string
 	arg          []string
 	valid        bool
+	validSet     bool // Valid has been called
 	file         bool
 	copytext     *string
 	largetype    *string
@@ -58,6 +64,7 @@ type Item struct {
 	actions      map[string][]string
 	icon         *Icon
 	noUID        bool // Suppress UID in JSON
+	pin          int  // Sort priority, see Pin()
 }
 
 // Title sets the title of the item in Alfred's results.
@@ -87,6 +94,15 @@ func (it *Item) Arg(s ...string) *Item {
 	return it
 }
 
+// ArgEmpty sets Item's arg to an empty string, so "arg" is present in the
+// JSON, for actions that expect an empty {query}. An Item whose arg hasn't
+// been set has no "arg" field, which Alfred 4.1+ allows for valid items.
+// Calling Arg() with no values removes the arg again.
+func (it *Item) ArgEmpty() *Item {
+	it.arg = []string{""}
+	return it
+}
+
 // UID sets Item's unique ID, which is used by Alfred to remember your choices.
 // Use a blank string to force results to appear in the order you add them.
 //
@@ -106,13 +122,25 @@ func (it *Item) Autocomplete(s string) *Item {
 	return it
 }
 
+// ExpandTo makes Item expand Alfred's query to query when actioned, i.e.
+// it sets Autocomplete to query and Valid to false.
+func (it *Item) ExpandTo(query string) *Item {
+	return it.Autocomplete(query).Valid(false)
+}
+
 // Valid tells Alfred whether the result is "actionable", i.e. ENTER will
 // pass Arg to subsequent action.
+//
+// Items are invalid by default unless Feedback.DefaultValid is set.
 func (it *Item) Valid(b bool) *Item {
 	it.valid = b
+	it.validSet = true
 	return it
 }
 
+// Invalid is a shortcut for Valid(false), for use with DefaultValid.
+func (it *Item) Invalid() *Item { return it.Valid(false) }
+
 // IsFile tells Alfred that this Item is a file, i.e. Arg is a path
 // and Alfred's File Actions should be made available.
 func (it *Item) IsFile(b bool) *Item {
@@ -120,6 +148,31 @@ func (it *Item) IsFile(b bool) *Item {
 	return it
 }
 
+// UID returns a stable UID for an Item identified by parts, e.g. the ID
+// and type of the object the Item represents. The same parts always produce
+// the same UID, so Alfred can learn the user's choices across runs, and
+// different parts (or the same strings split differently) produce different
+// ones. The UID is a hex-encoded SHA-1 hash.
+func UID(parts ...string) string {
+	h := sha1.New()
+	for _, s := range parts {
+		// prefix with length, so ("a b", "c") and ("a", "b c") differ
+		fmt.Fprintf(h, "%d:%s", len(s), s)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Pin sets the Item's sort priority. When Feedback is sorted or filtered,
+// Items with a higher priority are placed before those with a lower one
+// regardless of how well they match the query, and Items with the same
+// priority are sorted by score as normal. The default priority is 0, so
+// Items with a positive priority, e.g. an "Update available!" notice, are
+// shown above the other results. They are also never removed by Filter.
+func (it *Item) Pin(priority int) *Item {
+	it.pin = priority
+	return it
+}
+
 // Copytext is what CMD+C should copy instead of Arg (the default).
 func (it *Item) Copytext(s string) *Item {
 	it.copytext = &s
@@ -133,6 +186,18 @@ func (it *Item) Largetype(s string) *Item {
 	return it
 }
 
+// LargetypeJSON sets Largetype to v marshalled as indented JSON, which is
+// handy for inspecting data while debugging. If v can't be marshalled,
+// the error is logged and Largetype is left unchanged.
+func (it *Item) LargetypeJSON(v interface{}) *Item {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		log.Printf("[warning] marshal largetype JSON: %v", err)
+		return it
+	}
+	return it.Largetype(string(data))
+}
+
 // Quicklook is a path or URL shown in a macOS Quicklook window on SHIFT
 // or CMD+Y.
 func (it *Item) Quicklook(s string) *Item {
@@ -140,6 +205,25 @@ func (it *Item) Quicklook(s string) *Item {
 	return it
 }
 
+// QuicklookFile sets Item's arg and Quicklook path to path and marks it
+// as a file, so Alfred shows file actions for it.
+func (it *Item) QuicklookFile(path string) *Item {
+	return it.Arg(path).Quicklook(path).IsFile(true)
+}
+
+// QuicklookFirstExisting sets Item's Quicklook path to the first of paths
+// that exists. As Alfred only accepts one Quicklook value, this allows you to
+// provide fallbacks, e.g. a preview image followed by the file itself.
+// If none of the paths exist, Quicklook is not set.
+func (it *Item) QuicklookFirstExisting(paths ...string) *Item {
+	for _, p := range paths {
+		if _, err := os.Stat(p); err == nil {
+			return it.Quicklook(p)
+		}
+	}
+	return it
+}
+
 // Icon sets the icon for the Item.
 // Can point to an image file, a filepath of a file whose icon should be used,
 // or a UTI.
@@ -171,6 +255,16 @@ func (it *Item) ActionForType(typ string, value ...string) *Item {
 	return it
 }
 
+// Display sets value as the Item's arg, copy text, large type and
+// Universal Action text, for items whose purpose is to show a value
+// and let the user copy or otherwise use it.
+func (it *Item) Display(value string) *Item {
+	return it.Arg(value).
+		Copytext(value).
+		Largetype(value).
+		ActionForType("text", value)
+}
+
 // Var sets an Alfred variable for subsequent workflow elements.
 func (it *Item) Var(k, v string) *Item {
 	if it.vars == nil {
@@ -211,9 +305,15 @@ func (it *Item) SetModifier(m *Modifier) {
 	if it.mods == nil {
 		it.mods = map[string]*Modifier{}
 	}
+	m.item = it
 	it.mods[m.Key] = m
 }
 
+// Mod returns an initialised Modifier bound to this Item and the
+// combination of the given keys, e.g. it.Mod(ModCmd, ModShift) for ⌘⇧↩.
+// It is a synonym for NewModifier(). Combinations require Alfred 4+.
+func (it *Item) Mod(key ...string) *Modifier { return it.NewModifier(key...) }
+
 // Cmd returns an initialised Modifier bound to this Item and the CMD (⌘) key.
 func (it *Item) Cmd() *Modifier { return it.NewModifier(ModCmd) }
 
@@ -232,6 +332,14 @@ func (it *Item) Shift() *Modifier { return it.NewModifier(ModShift) }
 // Fn returns an initialised Modifier bound to this Item and the fn key.
 func (it *Item) Fn() *Modifier { return it.NewModifier(ModFn) }
 
+// CmdAlt returns an initialised Modifier bound to this Item and the
+// CMD+ALT (⌘⌥) keys. Requires Alfred 4+.
+func (it *Item) CmdAlt() *Modifier { return it.NewModifier(ModCmd, ModAlt) }
+
+// CmdShift returns an initialised Modifier bound to this Item and the
+// CMD+SHIFT (⌘⇧) keys. Requires Alfred 4+.
+func (it *Item) CmdShift() *Modifier { return it.NewModifier(ModCmd, ModShift) }
+
 // Vars returns the Item's workflow variables.
 func (it *Item) Vars() map[string]string {
 	return it.vars
@@ -320,13 +428,18 @@ type Modifier struct {
 	arg      []string
 	subtitle *string
 	valid    bool
+	validSet bool // Valid has been called
 	icon     *Icon
 	vars     map[string]string
+	item     *Item // Item the Modifier is bound to
 }
 
 // newModifier creates a Modifier, validating key.
 func newModifier(key ...string) *Modifier {
-	l := []string{}
+	var (
+		l    = []string{}
+		seen = map[string]bool{}
+	)
 	for _, k := range key {
 		s := strings.TrimSpace(strings.ToLower(k))
 		if s == "opt" {
@@ -339,6 +452,10 @@ func newModifier(key ...string) *Modifier {
 			log.Printf("[warning] ignored invalid modifier %q", k)
 			continue
 		}
+		if seen[s] {
+			continue
+		}
+		seen[s] = true
 		l = append(l, s)
 	}
 	sort.Strings(l)
@@ -358,9 +475,25 @@ func (m *Modifier) Subtitle(s string) *Modifier {
 	return m
 }
 
-// Valid sets the valid status for the Modifier.
+// InheritSubtitle sets the Modifier's subtitle to the current subtitle
+// of the Item it is bound to. Alfred shows the Item's subtitle if a
+// Modifier has none, but this makes it explicit in the generated JSON.
+//
+// As the subtitle is copied, later changes to the Item's subtitle do not
+// affect the Modifier. It does nothing if the Modifier isn't bound to an
+// Item or the Item has no subtitle.
+func (m *Modifier) InheritSubtitle() *Modifier {
+	if m.item != nil && m.item.subtitle != nil {
+		m.Subtitle(*m.item.subtitle)
+	}
+	return m
+}
+
+// Valid sets the valid status for the Modifier. If it isn't set,
+// the Modifier has the same valid status as the Item it is bound to.
 func (m *Modifier) Valid(v bool) *Modifier {
 	m.valid = v
+	m.validSet = true
 	return m
 }
 
@@ -387,16 +520,24 @@ func (m *Modifier) MarshalJSON() ([]byte, error) {
 	v := struct {
 		Arg       interface{}       `json:"arg,omitempty"`
 		Subtitle  *string           `json:"subtitle,omitempty"`
-		Valid     bool              `json:"valid,omitempty"`
+		Valid     *bool             `json:"valid,omitempty"`
 		Icon      *Icon             `json:"icon,omitempty"`
 		Variables map[string]string `json:"variables,omitempty"`
 	}{
 		Subtitle:  m.subtitle,
-		Valid:     m.valid,
 		Icon:      m.icon,
 		Variables: m.vars,
 	}
 
+	// an explicit valid is always sent, otherwise the Item's is inherited
+	valid := m.valid
+	if !m.validSet && m.item != nil {
+		valid = m.item.valid
+	}
+	if valid || m.validSet {
+		v.Valid = &valid
+	}
+
 	// serialise single arg as string
 	if len(m.arg) == 1 {
 		v.Arg = m.arg[0]
@@ -413,12 +554,32 @@ func (m *Modifier) MarshalJSON() ([]byte, error) {
 // NewItem(), SendFeedback(), etc. It is important to use the constructor
 // functions for Feedback, Item and Modifier structs so they are properly
 // initialised and bound to their parent.
+//
+// Feedback isn't safe for concurrent use. To add Items from multiple
+// goroutines, use an ItemCollector.
 type Feedback struct {
-	Items  []*Item           // The results to be sent to Alfred.
-	NoUIDs bool              // If true, suppress Item UIDs.
-	rerun  float64           // Tell Alfred to re-run Script Filter.
-	sent   bool              // Set to true when feedback has been sent.
-	vars   map[string]string // Top-level feedback variables.
+	Items  []*Item // The results to be sent to Alfred.
+	NoUIDs bool    // If true, suppress Item UIDs.
+	// If true, new Items are valid (see Item.Valid). Modifiers inherit
+	// their Item's valid status unless it is set with Modifier.Valid.
+	DefaultValid bool
+	// If greater than zero, Item subtitles are also matched when
+	// sorting. Items whose title (or match field) matches always rank
+	// above Items that only match on their subtitle, and the scores of
+	// subtitle-only matches are multiplied by SubtitleWeight.
+	SubtitleWeight float64
+	// Subtracted from the score of a matching Item for each character
+	// of its title (or match field) when sorting. A positive value
+	// favours shorter keys, and a negative value longer ones.
+	LengthPenalty float64
+	// If true, the query is split on whitespace and each term is matched
+	// independently, so terms may appear in any order. All terms must
+	// match, and the Item's score is the sum of the terms' scores.
+	SpaceSeparatedTerms bool
+	rerun               float64           // Tell Alfred to re-run Script Filter.
+	sent                bool              // Set to true when feedback has been sent.
+	compact             bool              // Send minified JSON.
+	vars                map[string]string // Top-level feedback variables.
 }
 
 // NewFeedback creates a new, initialised Feedback struct.
@@ -453,6 +614,72 @@ func (fb *Feedback) Clear() {
 	}
 }
 
+// RemoveItem removes it from Feedback. It returns false if it isn't
+// one of Feedback's Items.
+func (fb *Feedback) RemoveItem(it *Item) bool {
+	for i, item := range fb.Items {
+		if item == it {
+			fb.Items = append(fb.Items[:i], fb.Items[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Dedupe removes Items whose key, as returned by keyFunc, is the same
+// as that of an earlier Item, e.g. after merging results from several
+// sources. Items with an empty key are always retained.
+func (fb *Feedback) Dedupe(keyFunc func(it *Item) string) {
+	var (
+		seen  = map[string]bool{}
+		items = fb.Items[:0]
+	)
+	for _, it := range fb.Items {
+		key := keyFunc(it)
+		if key != "" {
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+		items = append(items, it)
+	}
+	fb.Items = items
+}
+
+// ItemSnapshot is a plain-data copy of the main fields of an Item.
+// Unset fields have their zero values.
+type ItemSnapshot struct {
+	Title    string
+	Subtitle string
+	Arg      []string
+	Valid    bool
+	Vars     map[string]string
+}
+
+// Snapshot returns a copy of the main fields of each of Feedback's Items,
+// e.g. for comparing a workflow's results with expected ones in tests.
+func (fb *Feedback) Snapshot() []ItemSnapshot {
+	snap := make([]ItemSnapshot, len(fb.Items))
+	for i, it := range fb.Items {
+		s := ItemSnapshot{Title: it.title, Valid: it.valid}
+		if it.subtitle != nil {
+			s.Subtitle = *it.subtitle
+		}
+		if len(it.arg) > 0 {
+			s.Arg = append([]string{}, it.arg...)
+		}
+		if len(it.vars) > 0 {
+			s.Vars = make(map[string]string, len(it.vars))
+			for k, v := range it.vars {
+				s.Vars[k] = v
+			}
+		}
+		snap[i] = s
+	}
+	return snap
+}
+
 // IsEmpty returns true if Feedback contains no items.
 func (fb *Feedback) IsEmpty() bool { return len(fb.Items) == 0 }
 
@@ -461,7 +688,7 @@ func (fb *Feedback) IsEmpty() bool { return len(fb.Items) == 0 }
 // The Item inherits any workflow variables set on the Feedback parent at
 // time of creation.
 func (fb *Feedback) NewItem(title string) *Item {
-	it := &Item{title: title, vars: map[string]string{}, noUID: fb.NoUIDs}
+	it := &Item{title: title, vars: map[string]string{}, noUID: fb.NoUIDs, valid: fb.DefaultValid}
 
 	// Add top-level variables to Item. The reason for this is that
 	// (older versions of) Alfred drops all item- and top-level variables
@@ -494,32 +721,116 @@ func (fb *Feedback) MarshalJSON() ([]byte, error) {
 // (by writing the JSON to STDOUT).
 //
 // You shouldn't need to call this directly: use SendFeedback() instead.
-func (fb *Feedback) Send() error {
+func (fb *Feedback) Send() error { return fb.SendTo(os.Stdout) }
+
+// SendTo is like Send, but writes the JSON to w instead of STDOUT.
+// As with Send, feedback is only written once: subsequent calls are
+// logged and ignored.
+func (fb *Feedback) SendTo(w io.Writer) error {
 	if fb.sent {
 		log.Printf("Feedback already sent. Ignoring.")
 		return nil
 	}
-	output, err := json.MarshalIndent(fb, "", "  ")
+	var (
+		output []byte
+		err    error
+	)
+	if fb.compact {
+		output, err = json.Marshal(fb)
+	} else {
+		output, err = json.MarshalIndent(fb, "", "  ")
+	}
 	if err != nil {
 		return fmt.Errorf("Error generating JSON : %w", err)
 	}
 
-	os.Stdout.Write(output)
+	if _, err := w.Write(output); err != nil {
+		return &writeError{err}
+	}
 	fb.sent = true
 	log.Printf("Sent %d result(s) to Alfred", len(fb.Items))
 	return nil
 }
 
+// writeError is returned by Feedback.SendTo if the JSON can't be written.
+type writeError struct{ err error }
+
+func (e *writeError) Error() string { return "write feedback: " + e.err.Error() }
+func (e *writeError) Unwrap() error { return e.err }
+
 // Sort sorts Items against query. Uses a fuzzy.Sorter with the specified
 // options.
+//
+// If SubtitleWeight is set, an Item matches if its title or subtitle
+// matches, and title matches are ranked above subtitle-only matches.
+// If SpaceSeparatedTerms is set, each word of query is matched separately.
+// If LengthPenalty is set, it is applied to the scores of matching Items.
 func (fb *Feedback) Sort(query string, opts ...fuzzy.Option) []*fuzzy.Result {
 	s := fuzzy.New(fb, opts...)
-	return s.Sort(query)
+	if fb.SubtitleWeight <= 0 && !fb.SpaceSeparatedTerms && fb.LengthPenalty == 0 && !fb.pinned() {
+		return s.Sort(query)
+	}
+	return fb.sortItems(s, query)
 }
 
-// Filter fuzzy-sorts Items against query and deletes Items that don't match.
-// It returns a slice of Result structs, which contain the results of the
-// fuzzy sorting.
+// pinned returns true if any Items have a sort priority.
+func (fb *Feedback) pinned() bool {
+	for _, it := range fb.Items {
+		if it.pin != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// sortItems scores Items' titles and subtitles separately.
+func (fb *Feedback) sortItems(s *fuzzy.Sorter, query string) []*fuzzy.Result {
+	rs := &resultSorter{
+		fb:      fb,
+		results: make([]*fuzzy.Result, len(fb.Items)),
+		subOnly: make([]bool, len(fb.Items)),
+	}
+	for i, it := range fb.Items {
+		key := fb.Keywords(i)
+		r := &fuzzy.Result{Query: query, SortKey: key}
+		// keep scores of non-matches, too, so they're sorted like fuzzy.Sorter does
+		r.Match, r.Score = fb.match(s, key, query)
+		if !r.Match && it.subtitle != nil && fb.SubtitleWeight > 0 {
+			if ok, score := fb.match(s, *it.subtitle, query); ok {
+				r.Match, r.Score = true, score*fb.SubtitleWeight
+				rs.subOnly[i] = true
+			}
+		}
+		if r.Match {
+			r.Score -= fb.LengthPenalty * float64(utf8.RuneCountInString(key))
+		}
+		rs.results[i] = r
+	}
+	sort.Stable(rs)
+	return rs.results
+}
+
+// match matches query against key, term by term if SpaceSeparatedTerms is set.
+func (fb *Feedback) match(s *fuzzy.Sorter, key, query string) (bool, float64) {
+	terms := strings.Fields(query)
+	if !fb.SpaceSeparatedTerms || len(terms) < 2 {
+		return s.Match(key, query)
+	}
+
+	var total float64
+	for _, term := range terms {
+		ok, score := s.Match(key, term)
+		if !ok {
+			return false, 0
+		}
+		total += score
+	}
+	return true, total
+}
+
+// Filter fuzzy-sorts Items against query and deletes Items that don't match,
+// except those pinned with a positive priority. It returns a slice of Result
+// structs, which contain the results of the fuzzy sorting.
 func (fb *Feedback) Filter(query string, opts ...fuzzy.Option) []*fuzzy.Result {
 	var (
 		items []*Item
@@ -528,7 +839,7 @@ func (fb *Feedback) Filter(query string, opts ...fuzzy.Option) []*fuzzy.Result {
 
 	r := fb.Sort(query, opts...)
 	for i, it := range fb.Items {
-		if r[i].Match {
+		if r[i].Match || it.pin > 0 {
 			items = append(items, it)
 			res = append(res, r[i])
 		}
@@ -561,13 +872,134 @@ func (fb *Feedback) Less(i, j int) bool {
 // Swap implements sort.Interface.
 func (fb *Feedback) Swap(i, j int) { fb.Items[i], fb.Items[j] = fb.Items[j], fb.Items[i] }
 
+// resultSorter sorts Feedback by pre-computed results.
+type resultSorter struct {
+	fb      *Feedback
+	results []*fuzzy.Result
+	subOnly []bool // Item only matches on its subtitle
+}
+
+// Len implements sort.Interface.
+func (rs *resultSorter) Len() int { return len(rs.results) }
+
+// Less implements sort.Interface. Higher priorities (Item.Pin) come
+// first, then matches, then title matches before subtitle-only ones,
+// then higher scores. Like fuzzy.Sorter, ties are sorted by SortKey.
+func (rs *resultSorter) Less(i, j int) bool {
+	if p, q := rs.fb.Items[i].pin, rs.fb.Items[j].pin; p != q {
+		return p > q
+	}
+	a, b := rs.results[i], rs.results[j]
+	if a.Match != b.Match {
+		return a.Match
+	}
+	if rs.subOnly[i] != rs.subOnly[j] {
+		return rs.subOnly[j]
+	}
+	if a.Score != b.Score {
+		return a.Score > b.Score
+	}
+	return a.SortKey < b.SortKey
+}
+
+// Swap implements sort.Interface.
+func (rs *resultSorter) Swap(i, j int) {
+	rs.fb.Swap(i, j)
+	rs.results[i], rs.results[j] = rs.results[j], rs.results[i]
+	rs.subOnly[i], rs.subOnly[j] = rs.subOnly[j], rs.subOnly[i]
+}
+
+// MapSortable implements fuzzy.Sortable for the keys of a map. As map
+// iteration order is random, keys are sorted first, so Items with equal
+// scores are always returned in the same order.
+type MapSortable struct {
+	Keys     []string // Map keys, sorted by fuzzy.Sort
+	keywords func(key string) string
+}
+
+// NewMapSortable returns a MapSortable for map m, which must have string
+// keys. keywords returns the text to match a key's entry against. If it is
+// nil, the key itself is matched. It panics if m isn't a map with string
+// keys.
+//
+// After sorting, use MapSortable.Keys to retrieve the map entries in order.
+func NewMapSortable(m interface{}, keywords func(key string) string) *MapSortable {
+	v := reflect.ValueOf(m)
+	if v.Kind() != reflect.Map || v.Type().Key().Kind() != reflect.String {
+		panic(fmt.Sprintf("not a map with string keys: %T", m))
+	}
+
+	keys := make([]string, v.Len())
+	for i, k := range v.MapKeys() {
+		keys[i] = k.String()
+	}
+	sort.Strings(keys)
+
+	if keywords == nil {
+		keywords = func(key string) string { return key }
+	}
+	return &MapSortable{Keys: keys, keywords: keywords}
+}
+
+// Keywords implements fuzzy.Sortable.
+func (s *MapSortable) Keywords(i int) string { return s.keywords(s.Keys[i]) }
+
+// Len implements sort.Interface.
+func (s *MapSortable) Len() int { return len(s.Keys) }
+
+// Less implements sort.Interface.
+func (s *MapSortable) Less(i, j int) bool { return s.Keys[i] < s.Keys[j] }
+
+// Swap implements sort.Interface.
+func (s *MapSortable) Swap(i, j int) { s.Keys[i], s.Keys[j] = s.Keys[j], s.Keys[i] }
+
+// MergedResult is the fuzzy.Result for one item of one of the sources
+// passed to MergeSorted.
+type MergedResult struct {
+	*fuzzy.Result
+	Source int // Index of the item's source in the arguments to MergeSorted
+	Index  int // Index of the item within its source
+}
+
+// MergeSorted fuzzy-matches the items of several sources against query and
+// merges the results into a single slice sorted by score, matches first.
+// Use MergedResult.Source and MergedResult.Index to retrieve the
+// corresponding item. Ties are ordered by source, then by index.
+//
+// Unlike Feedback.Sort, the sources themselves are not reordered.
+func MergeSorted(query string, sources []fuzzy.Sortable, opts ...fuzzy.Option) []*MergedResult {
+	var results []*MergedResult
+	for i, src := range sources {
+		s := fuzzy.New(src, opts...)
+		for j := 0; j < src.Len(); j++ {
+			key := src.Keywords(j)
+			r := &fuzzy.Result{Query: query, SortKey: key}
+			r.Match, r.Score = s.Match(key, query)
+			if !r.Match {
+				r.Score = 0
+			}
+			results = append(results, &MergedResult{Result: r, Source: i, Index: j})
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Match != b.Match {
+			return a.Match
+		}
+		return a.Score > b.Score
+	})
+	return results
+}
+
 // ArgVars lets you set workflow variables from Run Script actions.
 // It emits the arg and variables you set in the format required by Alfred.
 //
 // Use ArgVars.Send() to pass variables to downstream workflow elements.
 type ArgVars struct {
-	arg  []string
-	vars map[string]string
+	arg      []string
+	vars     map[string]string
+	outputTo []string // variables arg is copied to
 }
 
 // NewArgVars returns an initialised ArgVars object.
@@ -596,12 +1028,51 @@ func (a *ArgVars) Var(k, v string) *ArgVars {
 	return a
 }
 
+// OutputTo also sets the workflow variable varName to the arg, so the
+// value is still available to workflow elements further downstream that
+// receive a different {query}. Multiple args are joined with tabs, as in
+// Alfred's {query}. Call OutputTo multiple times to set several variables.
+//
+// The variable is set when ArgVars is sent, so it always contains the
+// current arg, and takes precedence over a value set with Var().
+func (a *ArgVars) OutputTo(varName string) *ArgVars {
+	a.outputTo = append(a.outputTo, varName)
+	return a
+}
+
+// allVars returns variables set with Var() and OutputTo().
+func (a *ArgVars) allVars() map[string]string {
+	if len(a.outputTo) == 0 {
+		return a.vars
+	}
+	m := make(map[string]string, len(a.vars)+len(a.outputTo))
+	for k, v := range a.vars {
+		m[k] = v
+	}
+	for _, k := range a.outputTo {
+		m[k] = strings.Join(a.arg, "\t")
+	}
+	return m
+}
+
+// VarsFromConfig copies the current values of the named variables from
+// cfg, e.g. to pass settings on to downstream workflow elements.
+// Variables that aren't set are ignored.
+func (a *ArgVars) VarsFromConfig(cfg *Config, keys ...string) *ArgVars {
+	for _, k := range keys {
+		if v, ok := cfg.Lookup(k); ok {
+			a.vars[k] = v
+		}
+	}
+	return a
+}
+
 // String returns a string representation.
 //
 // If any variables are set, JSON is returned. Otherwise, a plain string
 // is returned.
 func (a *ArgVars) String() (string, error) {
-	if len(a.vars) == 0 && len(a.arg) < 2 {
+	if len(a.allVars()) == 0 && len(a.arg) < 2 {
 		if len(a.arg) == 0 {
 			return "", nil
 		}
@@ -630,7 +1101,8 @@ func (a *ArgVars) Send() error {
 func (a *ArgVars) MarshalJSON() ([]byte, error) {
 	// Return arg regardless of whether it's empty or not:
 	// we have to return *something*
-	if len(a.vars) == 0 && len(a.arg) < 2 {
+	vars := a.allVars()
+	if len(vars) == 0 && len(a.arg) < 2 {
 		// Want empty string, i.e. "", not null
 		if len(a.arg) == 0 {
 			return []byte(`""`), nil
@@ -642,7 +1114,7 @@ func (a *ArgVars) MarshalJSON() ([]byte, error) {
 		Arg  interface{}       `json:"arg,omitempty"`
 		Vars map[string]string `json:"variables,omitempty"`
 	}{
-		Vars: a.vars,
+		Vars: vars,
 	}
 
 	if len(a.arg) == 1 {
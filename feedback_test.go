@@ -4,12 +4,14 @@
 package aw
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.deanishe.net/fuzzy"
 )
 
 func TestItem_Icon(t *testing.T) {
@@ -34,6 +36,92 @@ func TestFeedback_IsEmpty(t *testing.T) {
 	assert.False(t, fb.IsEmpty(), "feedback empty")
 }
 
+// Items are removed by pointer.
+func TestFeedback_RemoveItem(t *testing.T) {
+	t.Parallel()
+
+	fb := NewFeedback()
+	one := fb.NewItem("one")
+	two := fb.NewItem("two")
+	three := fb.NewItem("three")
+
+	assert.True(t, fb.RemoveItem(two), "item not removed")
+	assert.Equal(t, []*Item{one, three}, fb.Items, "unexpected items")
+	assert.False(t, fb.RemoveItem(two), "removed item removed again")
+	assert.False(t, fb.RemoveItem(&Item{title: "one"}), "removed equal item")
+	assert.Equal(t, []*Item{one, three}, fb.Items, "unexpected items")
+}
+
+// Dedupe keeps the first Item with a given key.
+func TestFeedback_Dedupe(t *testing.T) {
+	t.Parallel()
+
+	fb := NewFeedback()
+	a := fb.NewItem("one").UID("a")
+	b := fb.NewItem("two").UID("b")
+	fb.NewItem("one again").UID("a")
+	c := fb.NewItem("three")
+	d := fb.NewItem("four")
+	fb.NewItem("two again").UID("b")
+
+	fb.Dedupe(func(it *Item) string {
+		if it.uid == nil {
+			return ""
+		}
+		return *it.uid
+	})
+	assert.Equal(t, []*Item{a, b, c, d}, fb.Items, "unexpected items")
+
+	fb.NewItem("three")
+	fb.Dedupe(func(it *Item) string { return it.title })
+	assert.Equal(t, []*Item{a, b, c, d}, fb.Items, "unexpected items")
+}
+
+// Snapshot copies Items' main fields.
+func TestFeedback_Snapshot(t *testing.T) {
+	t.Parallel()
+
+	fb := NewFeedback()
+	assert.Equal(t, []ItemSnapshot{}, fb.Snapshot(), "unexpected snapshot")
+
+	fb.NewItem("one")
+	fb.Var("key", "value")
+	fb.NewItem("two").Subtitle("second").Arg("2").Valid(true)
+	fb.NewItem("three").Arg("a", "b").Var("other", "x").UID("3")
+
+	x := []ItemSnapshot{
+		{Title: "one"},
+		{Title: "two", Subtitle: "second", Arg: []string{"2"}, Valid: true,
+			Vars: map[string]string{"key": "value"}},
+		{Title: "three", Arg: []string{"a", "b"},
+			Vars: map[string]string{"key": "value", "other": "x"}},
+	}
+	snap := fb.Snapshot()
+	assert.Equal(t, x, snap, "unexpected snapshot")
+
+	// snapshot is a copy
+	snap[2].Arg[0] = "changed"
+	snap[2].Vars["key"] = "changed"
+	assert.Equal(t, x, fb.Snapshot(), "snapshot not copied")
+}
+
+// SendTo writes feedback to a writer only once.
+func TestFeedback_SendTo(t *testing.T) {
+	t.Parallel()
+
+	fb := NewFeedback()
+	fb.NewItem("one").Arg("1")
+	buf := &bytes.Buffer{}
+	require.Nil(t, fb.SendTo(buf), "SendTo failed")
+	x := "{\n  \"items\": [\n    {\n      \"title\": \"one\",\n" +
+		"      \"arg\": \"1\",\n      \"valid\": false\n    }\n  ]\n}"
+	assert.Equal(t, x, buf.String(), "unexpected output")
+	assert.True(t, fb.sent, "sent not set")
+
+	require.Nil(t, fb.SendTo(buf), "SendTo failed")
+	assert.Equal(t, x, buf.String(), "feedback sent twice")
+}
+
 func TestItem_MarshalJSON(t *testing.T) {
 	t.Parallel()
 
@@ -207,6 +295,18 @@ func TestArgVars_MarshalJSON(t *testing.T) {
 		// Multiple variables and arg
 		{in: &ArgVars{arg: []string{"title"}, vars: map[string]string{"foo": "bar", "ducky": "fuzz"}},
 			x: `{"alfredworkflow":{"arg":"title","variables":{"ducky":"fuzz","foo":"bar"}}}`},
+		// Arg copied to variable
+		{in: NewArgVars().Arg("title").OutputTo("name"),
+			x: `{"alfredworkflow":{"arg":"title","variables":{"name":"title"}}}`},
+		// Arg copied to multiple variables, overriding Var
+		{in: NewArgVars().Var("name", "old").OutputTo("name").OutputTo("copy").Arg("title"),
+			x: `{"alfredworkflow":{"arg":"title","variables":{"copy":"title","name":"title"}}}`},
+		// Multiple args copied to variable
+		{in: NewArgVars().Arg("one", "two").OutputTo("name"),
+			x: `{"alfredworkflow":{"arg":["one","two"],"variables":{"name":"one\ttwo"}}}`},
+		// Empty arg copied to variable
+		{in: NewArgVars().OutputTo("name"),
+			x: `{"alfredworkflow":{"variables":{"name":""}}}`},
 	}
 
 	for i, td := range tests {
@@ -274,6 +374,24 @@ func TestArgVars_Vars(t *testing.T) {
 	assert.Equal(t, vars, av.Vars(), "Unexpected Vars")
 }
 
+// Vars copied from Config
+func TestArgVars_VarsFromConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := NewConfig(MapEnv{
+		"API_URL":  "https://api.example.com",
+		"USERNAME": "dave",
+		"EMPTY":    "",
+		"OTHER":    "not copied",
+	})
+
+	av := NewArgVars().Arg("title").VarsFromConfig(cfg, "API_URL", "USERNAME", "EMPTY", "UNSET")
+	data, err := json.Marshal(av)
+	require.Nil(t, err, "marshal ArgVars failed")
+	x := `{"alfredworkflow":{"arg":"title","variables":{"API_URL":"https://api.example.com","EMPTY":"","USERNAME":"dave"}}}`
+	assert.Equal(t, x, string(data), "unexpected JSON")
+}
+
 // Marshal Feedback to JSON
 func TestFeedback_MarshalJSON(t *testing.T) {
 	t.Parallel()
@@ -399,6 +517,29 @@ func TestModifierShortcuts(t *testing.T) {
 	}
 }
 
+// Combined modifier keys are sorted and joined with "+".
+func TestItem_Mod(t *testing.T) {
+	t.Parallel()
+
+	it := &Item{}
+	tests := []struct {
+		m *Modifier
+		k string
+	}{
+		{it.Mod(ModCmd), "cmd"},
+		{it.Mod(ModShift, ModCmd), "cmd+shift"},
+		{it.Mod(ModShift, ModOpt, ModCmd), "alt+cmd+shift"},
+		{it.Mod(ModCmd, "CMD", ModCtrl), "cmd+ctrl"},
+		{it.CmdAlt(), "alt+cmd"},
+		{it.CmdShift(), "cmd+shift"},
+	}
+
+	for _, td := range tests {
+		assert.Equal(t, td.k, td.m.Key, "Bad modkey for %q", td.k)
+	}
+	assert.Equal(t, 5, len(it.mods), "unexpected number of modifiers")
+}
+
 // TestFeedback_Rerun verifies that rerun is properly set.
 func TestFeedback_Rerun(t *testing.T) {
 	t.Parallel()
@@ -492,6 +633,162 @@ func TestItem_methods(t *testing.T) {
 	assert.Equal(t, qlURL, *it.ql, "Bad quicklook URL")
 }
 
+// ExpandTo sets autocomplete and makes Item invalid.
+func TestItem_ExpandTo(t *testing.T) {
+	t.Parallel()
+
+	it := (&Item{title: "title"}).Valid(true).ExpandTo("workflow:update")
+	data, err := json.Marshal(it)
+	require.Nil(t, err, "marshal Item")
+	x := `{"title":"title","autocomplete":"workflow:update","valid":false}`
+	assert.Equal(t, x, string(data), "unexpected JSON")
+}
+
+// Display sets all value fields.
+func TestItem_Display(t *testing.T) {
+	t.Parallel()
+
+	it := (&Item{title: "title"}).Display("snippet")
+	data, err := json.Marshal(it)
+	require.Nil(t, err, "marshal Item")
+	x := `{"title":"title","arg":"snippet","valid":false,"text":{"copy":"snippet","largetype":"snippet"},"action":{"text":["snippet"]}}`
+	assert.Equal(t, x, string(data), "unexpected JSON")
+}
+
+// ArgEmpty emits an empty arg; no arg is omitted.
+func TestItem_ArgEmpty(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		in *Item
+		x  string
+	}{
+		{(&Item{title: "title"}).Valid(true),
+			`{"title":"title","valid":true}`},
+		{(&Item{title: "title"}).Valid(true).ArgEmpty(),
+			`{"title":"title","arg":"","valid":true}`},
+		{(&Item{title: "title"}).Valid(true).ArgEmpty().Arg(),
+			`{"title":"title","valid":true}`},
+	}
+
+	for _, td := range tests {
+		data, err := json.Marshal(td.in)
+		require.Nil(t, err, "marshal Item")
+		assert.Equal(t, td.x, string(data), "unexpected JSON")
+	}
+}
+
+// UIDs are stable and distinct.
+func TestUID(t *testing.T) {
+	t.Parallel()
+
+	a := UID("book", "123")
+	assert.Equal(t, a, UID("book", "123"), "UID not stable")
+	assert.Equal(t, 40, len(a), "unexpected UID length")
+
+	others := [][]string{
+		{},
+		{""},
+		{"book"},
+		{"book", "124"},
+		{"author", "123"},
+		{"book123"},
+		{"book1", "23"},
+		{"123", "book"},
+		{"book", "123", ""},
+	}
+	seen := map[string]bool{a: true}
+	for _, parts := range others {
+		uid := UID(parts...)
+		assert.False(t, seen[uid], "duplicate UID for %q", parts)
+		seen[uid] = true
+	}
+}
+
+// LargetypeJSON sets largetype to indented JSON.
+func TestItem_LargetypeJSON(t *testing.T) {
+	t.Parallel()
+
+	v := struct {
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}{"AwGo", 2}
+	it := (&Item{title: "title"}).LargetypeJSON(v)
+	require.NotNil(t, it.largetype, "largetype not set")
+	assert.Equal(t, "{\n  \"name\": \"AwGo\",\n  \"count\": 2\n}", *it.largetype, "unexpected largetype")
+
+	// unmarshallable value
+	it = (&Item{title: "title"}).LargetypeJSON(make(chan int))
+	assert.Nil(t, it.largetype, "largetype set")
+}
+
+// QuicklookFile sets arg, quicklookurl and type.
+func TestItem_QuicklookFile(t *testing.T) {
+	t.Parallel()
+
+	it := (&Item{title: "title"}).QuicklookFile("/path/to/file.txt")
+	data, err := json.Marshal(it)
+	require.Nil(t, err, "marshal Item")
+	x := `{"title":"title","arg":"/path/to/file.txt","valid":false,"type":"file","quicklookurl":"/path/to/file.txt"}`
+	assert.Equal(t, x, string(data), "unexpected JSON")
+}
+
+// Quicklook path is the first path that exists.
+func TestItem_QuicklookFirstExisting(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		paths []string
+		x     string
+	}{
+		{"empty", []string{}, ""},
+		{"none exist", []string{"./does-not-exist", "./nor-does-this"}, ""},
+		{"first exists", []string{"./testdata/info.plist", "./testdata"}, "./testdata/info.plist"},
+		{"fallback", []string{"./does-not-exist", "./testdata/subdir", "./testdata"}, "./testdata/subdir"},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			t.Parallel()
+			it := (&Item{}).QuicklookFirstExisting(td.paths...)
+			if td.x == "" {
+				assert.Nil(t, it.ql, "Non-nil quicklook")
+				return
+			}
+			require.NotNil(t, it.ql, "Nil quicklook")
+			assert.Equal(t, td.x, *it.ql, "unexpected quicklook")
+		})
+	}
+}
+
+// Modifier copies Item's subtitle.
+func TestModifier_InheritSubtitle(t *testing.T) {
+	t.Parallel()
+
+	it := &Item{title: "title"}
+	m := it.Cmd().InheritSubtitle()
+	assert.Nil(t, m.subtitle, "subtitle set from empty Item")
+
+	it.Subtitle("item subtitle")
+	m = it.Cmd().Arg("cmd").InheritSubtitle()
+	require.NotNil(t, m.subtitle, "subtitle not inherited")
+	assert.Equal(t, "item subtitle", *m.subtitle, "unexpected subtitle")
+
+	// subtitle is copied at call time
+	it.Subtitle("new subtitle")
+	assert.Equal(t, "item subtitle", *m.subtitle, "modifier subtitle changed")
+
+	data, err := json.Marshal(m)
+	require.Nil(t, err, "marshal Modifier")
+	assert.Equal(t, `{"arg":"cmd","subtitle":"item subtitle"}`, string(data), "unexpected JSON")
+
+	// unbound Modifier
+	m = newModifier(ModAlt).InheritSubtitle()
+	assert.Nil(t, m.subtitle, "unbound modifier has subtitle")
+}
+
 // TestModifier_methods verifies Modifier methods.
 func TestModifier_methods(t *testing.T) {
 	var (
@@ -523,6 +820,41 @@ func TestModifier_methods(t *testing.T) {
 	assert.Equal(t, icon.Value, m.icon.Value, "Bad icon value")
 }
 
+// Modifiers inherit valid from their Item unless it's set explicitly.
+func TestModifier_validInherit(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		itemValid bool
+		modValid  *bool
+		x         string
+	}{
+		{true, nil, `{"valid":true}`},
+		{false, nil, `{}`},
+		{true, boolPtr(false), `{"valid":false}`},
+		{false, boolPtr(true), `{"valid":true}`},
+		{false, boolPtr(false), `{"valid":false}`},
+	}
+
+	for _, td := range tests {
+		it := (&Item{title: "title"}).Valid(td.itemValid)
+		m := it.NewModifier(ModCmd)
+		if td.modValid != nil {
+			m.Valid(*td.modValid)
+		}
+		data, err := json.Marshal(m)
+		require.Nil(t, err, "marshal Modifier")
+		assert.Equal(t, td.x, string(data), "unexpected JSON")
+	}
+
+	// unbound Modifier
+	data, err := json.Marshal(&Modifier{})
+	require.Nil(t, err, "marshal Modifier")
+	assert.Equal(t, `{}`, string(data), "unexpected JSON")
+}
+
+func boolPtr(b bool) *bool { return &b }
+
 // Sorts Feedback.Items
 func TestFeedback_Sort(t *testing.T) {
 	for _, td := range feedbackTitles {
@@ -538,6 +870,248 @@ func TestFeedback_Sort(t *testing.T) {
 	}
 }
 
+// Title matches rank above subtitle matches.
+func TestFeedback_Sort_subtitleWeight(t *testing.T) {
+	t.Parallel()
+
+	newFeedback := func(weight float64) *Feedback {
+		fb := NewFeedback()
+		fb.SubtitleWeight = weight
+		fb.NewItem("no match").Subtitle("nothing")
+		fb.NewItem("Documents").Subtitle("Safari bookmarks")
+		fb.NewItem("Safari").Subtitle("web browser")
+		return fb
+	}
+
+	// subtitles ignored by default
+	fb := newFeedback(0)
+	fb.Filter("safari")
+	require.Equal(t, 1, len(fb.Items), "unexpected result count")
+	assert.Equal(t, "Safari", fb.Items[0].title, "unexpected title")
+
+	fb = newFeedback(0.5)
+	r := fb.Sort("safari")
+	x := []string{"Safari", "Documents", "no match"}
+	for i, it := range fb.Items {
+		assert.Equal(t, x[i], it.title, "unexpected title")
+	}
+	assert.Equal(t, []bool{true, true, false}, []bool{r[0].Match, r[1].Match, r[2].Match}, "unexpected matches")
+
+	// Long keys have negative scores, which weighting mustn't lift
+	// above a title match, and matching subtitles don't lower the
+	// score of a title match.
+	fb = NewFeedback()
+	fb.SubtitleWeight = 0.1
+	fb.NewItem("Documents").Subtitle("Safari bookmarks and reading list items synced from your devices")
+	fb.NewItem("Sync settings, browser extensions and other things for Safari").Subtitle("Safari")
+	fb.NewItem("Web browser made by Apple: Safari").Subtitle("no match")
+	r = fb.Sort("safari")
+	x = []string{
+		"Web browser made by Apple: Safari",
+		"Sync settings, browser extensions and other things for Safari",
+		"Documents",
+	}
+	for i, it := range fb.Items {
+		assert.Equal(t, x[i], it.title, "unexpected title")
+	}
+	assert.True(t, r[2].Score > r[1].Score, "subtitle-only score not higher")
+	_, score := fuzzy.New(nil).Match(x[1], "safari")
+	assert.Equal(t, score, r[1].Score, "subtitle match changed title score")
+}
+
+// LengthPenalty favours shorter or longer keys.
+func TestFeedback_Sort_lengthPenalty(t *testing.T) {
+	t.Parallel()
+
+	sorted := func(penalty float64) []string {
+		fb := NewFeedback()
+		fb.LengthPenalty = penalty
+		fb.NewItem("no match")
+		fb.NewItem("Safari Bookmarks")
+		fb.NewItem("Safari Books")
+		fb.Sort("safari boo")
+		var titles []string
+		for _, it := range fb.Items {
+			titles = append(titles, it.title)
+		}
+		return titles
+	}
+
+	assert.Equal(t, []string{"Safari Books", "Safari Bookmarks", "no match"}, sorted(2), "shorter key not first")
+	assert.Equal(t, []string{"Safari Bookmarks", "Safari Books", "no match"}, sorted(-2), "longer key not first")
+}
+
+// Pinned items are sorted first regardless of match.
+func TestFeedback_Sort_pin(t *testing.T) {
+	t.Parallel()
+
+	newFeedback := func() *Feedback {
+		fb := NewFeedback()
+		fb.NewItem("Safari Books")
+		fb.NewItem("Update available!").Pin(10)
+		fb.NewItem("no match")
+		fb.NewItem("Safari")
+		fb.NewItem("Sticky safari").Pin(5)
+		fb.NewItem("Last").Pin(-1)
+		return fb
+	}
+	titles := func(fb *Feedback) []string {
+		var l []string
+		for _, it := range fb.Items {
+			l = append(l, it.title)
+		}
+		return l
+	}
+
+	fb := newFeedback()
+	res := fb.Sort("safari")
+	assert.Equal(t, []string{"Update available!", "Sticky safari", "Safari", "Safari Books", "no match", "Last"},
+		titles(fb), "unexpected order")
+	assert.False(t, res[0].Match, "unexpected match")
+	assert.True(t, res[1].Match, "unexpected non-match")
+
+	fb = newFeedback()
+	fb.Filter("safari")
+	assert.Equal(t, []string{"Update available!", "Sticky safari", "Safari", "Safari Books"},
+		titles(fb), "unexpected filtered items")
+
+	// Pinning an Item doesn't change the order of the others, including
+	// those with equal scores.
+	unpinned := func() *Feedback {
+		fb := NewFeedback()
+		for _, s := range []string{"Web Videos", "Web Search", "no match", "Web Images", "other"} {
+			fb.NewItem(s)
+		}
+		return fb
+	}
+	fb = unpinned()
+	fb.Sort("web")
+	x := titles(fb)
+	require.Equal(t, []string{"Web Images", "Web Search", "Web Videos"}, x[:3], "equal scores not sorted by key")
+
+	fb = unpinned()
+	fb.NewItem("Update available!").Pin(1)
+	fb.Sort("web")
+	assert.Equal(t, append([]string{"Update available!"}, x...), titles(fb), "unexpected order")
+}
+
+// Query terms are matched independently.
+func TestFeedback_Filter_spaceSeparatedTerms(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		q  string
+		on bool
+		x  []string
+	}{
+		{"game thrones", true, []string{"Game of Thrones"}},
+		// the query is a subsequence of the title (its space matches the
+		// one after "Game"), so it matches without SpaceSeparatedTerms, too
+		{"game thrones", false, []string{"Game of Thrones"}},
+		{"thrones game", false, []string{}},
+		{"thrones game", true, []string{"Game of Thrones"}},
+		{"of game", true, []string{"Game of Thrones"}},
+		{"game wire", true, []string{}},
+		{"girl", true, []string{"Gone Girl"}},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("%q, on=%v", td.q, td.on), func(t *testing.T) {
+			t.Parallel()
+			fb := NewFeedback()
+			fb.SpaceSeparatedTerms = td.on
+			fb.NewItem("Game of Thrones")
+			fb.NewItem("The Wire")
+			fb.NewItem("Gone Girl")
+			fb.Filter(td.q)
+
+			titles := []string{}
+			for _, it := range fb.Items {
+				titles = append(titles, it.title)
+			}
+			assert.Equal(t, td.x, titles, "unexpected results")
+		})
+	}
+}
+
+// Map entries with equal scores are always sorted in the same order.
+func TestMapSortable(t *testing.T) {
+	t.Parallel()
+
+	hosts := map[string]string{
+		"web3": "example.com",
+		"web1": "example.com",
+		"mail": "mail.example.com",
+		"web2": "example.com",
+		"db":   "db.internal",
+	}
+	keywords := func(key string) string { return hosts[key] }
+
+	var first []string
+	for i := 0; i < 20; i++ {
+		s := NewMapSortable(hosts, keywords)
+		res := fuzzy.Sort(s, "example")
+		if i == 0 {
+			first = append([]string{}, s.Keys...)
+			assert.Equal(t, []string{"web1", "web2", "web3"}, first[:3], "equal scores not sorted by key")
+			assert.False(t, res[len(res)-1].Match, "non-match not last")
+			continue
+		}
+		assert.Equal(t, first, s.Keys, "unstable order")
+	}
+
+	// keys used as keywords by default
+	s := NewMapSortable(map[string]int{"b": 2, "a": 1}, nil)
+	assert.Equal(t, []string{"a", "b"}, s.Keys, "keys not sorted")
+	assert.Equal(t, "b", s.Keywords(1), "unexpected keywords")
+
+	assert.Panics(t, func() { NewMapSortable([]string{}, nil) }, "accepted slice")
+	assert.Panics(t, func() { NewMapSortable(map[int]string{}, nil) }, "accepted int keys")
+}
+
+// stringSlice implements fuzzy.Sortable.
+type stringSlice []string
+
+func (s stringSlice) Keywords(i int) string { return s[i] }
+func (s stringSlice) Len() int              { return len(s) }
+func (s stringSlice) Less(i, j int) bool    { return s[i] < s[j] }
+func (s stringSlice) Swap(i, j int)         { s[i], s[j] = s[j], s[i] }
+
+// Results from multiple sources are sorted by score.
+func TestMergeSorted(t *testing.T) {
+	t.Parallel()
+
+	bookmarks := stringSlice{"Yahoo", "Google Maps", "Gmail"}
+	history := stringSlice{"GitHub Issues", "gm", "Bing"}
+	sources := []fuzzy.Sortable{bookmarks, history}
+
+	res := MergeSorted("gm", sources)
+	require.Equal(t, len(bookmarks)+len(history), len(res), "unexpected result count")
+
+	// exact match is first
+	assert.Equal(t, 1, res[0].Source, "unexpected source")
+	assert.Equal(t, 1, res[0].Index, "unexpected index")
+
+	var nomatch bool
+	for i, r := range res {
+		src := sources[r.Source].(stringSlice)
+		assert.Equal(t, src[r.Index], r.SortKey, "bad back-reference")
+		if !r.Match {
+			nomatch = true
+			continue
+		}
+		assert.False(t, nomatch, "match %q sorted after non-match", r.SortKey)
+		if i > 0 {
+			assert.True(t, r.Score <= res[i-1].Score, "%q sorted after lower score", r.SortKey)
+		}
+	}
+	assert.False(t, res[len(res)-1].Match, "non-matches not last")
+
+	// sources are not reordered
+	assert.Equal(t, stringSlice{"Yahoo", "Google Maps", "Gmail"}, bookmarks, "source reordered")
+}
+
 var feedbackTitles = []struct {
 	q   string
 	in  []string
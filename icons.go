@@ -3,6 +3,16 @@
 
 package aw
 
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/deanishe/awgo/util"
+)
+
 // IconType specifies the type of an aw.Icon struct. It can be an image file,
 // the icon of a file, e.g. an application's icon, or the icon for a UTI.
 type IconType string
@@ -88,3 +98,95 @@ type Icon struct {
 	Value string   `json:"path"`           // Path or UTI
 	Type  IconType `json:"type,omitempty"` // "fileicon", "filetype" or ""
 }
+
+// JXA script to render an emoji (argv[0]) to a PNG file (argv[1]).
+const emojiScript = `ObjC.import('AppKit');
+function run(argv) {
+	var size = 256,
+		str = $.NSString.alloc.initWithUTF8String(argv[0]),
+		font = $.NSFont.systemFontOfSize(size * 0.8),
+		attrs = $.NSDictionary.dictionaryWithObjectForKey(font, $.NSFontAttributeName),
+		dim = str.sizeWithAttributes(attrs),
+		img = $.NSImage.alloc.initWithSize($.NSMakeSize(size, size));
+
+	img.lockFocus;
+	str.drawAtPointWithAttributes($.NSMakePoint((size - dim.width) / 2, (size - dim.height) / 2), attrs);
+	img.unlockFocus;
+
+	var rep = $.NSBitmapImageRep.imageRepWithData(img.TIFFRepresentation),
+		png = rep.representationUsingTypeProperties($.NSPNGFileType, $());
+	if (!png.writeToFileAtomically(argv[1], true)) throw new Error('could not write ' + argv[1]);
+}`
+
+// renderEmoji draws emoji to a PNG file at path.
+var renderEmoji = func(emoji, path string) error {
+	_, err := util.RunJS(emojiScript, emoji, path)
+	return err
+}
+
+// EmojiIcon returns an Icon showing emoji. As Alfred can't use text as
+// an icon, the emoji is rendered to a PNG file in AwGo's cache directory
+// the first time it is requested, and the cached file is used thereafter.
+//
+// If the emoji can't be rendered, the error is logged and EmojiIcon
+// returns nil, which Item.Icon treats as "no icon".
+func (wf *Workflow) EmojiIcon(emoji string) *Icon {
+	dir := util.MustExist(filepath.Join(wf.awCacheDir(), "emoji"))
+	path := filepath.Join(dir, fmt.Sprintf("%x.png", emoji))
+	if !util.PathExists(path) {
+		if err := renderEmoji(emoji, path); err != nil {
+			log.Printf("[warning] render emoji %q: %v", emoji, err)
+			return nil
+		}
+	}
+	return &Icon{Value: path}
+}
+
+// JXA script to print the UTI of the file at argv[0].
+const utiScript = `ObjC.import('AppKit');
+function run(argv) {
+	var uti = $.NSWorkspace.sharedWorkspace.typeOfFileError(argv[0], $());
+	if (uti.isNil()) throw new Error('could not get type of ' + argv[0]);
+	return ObjC.unwrap(uti);
+}`
+
+// fileUTI returns the UTI of the file at path.
+var fileUTI = func(path string) (string, error) {
+	s, err := util.RunJS(utiScript, path)
+	return strings.TrimSpace(s), err
+}
+
+var (
+	fileTypeIcons   = map[string]*Icon{} // extension -> filetype Icon
+	fileTypeIconsMu sync.Mutex
+)
+
+// FileTypeIcon returns an Icon for the type of the file at path, such as
+// the generic icon for PDF documents. The type is determined only once
+// per file extension, and files with the same extension share the same
+// Icon, so it's much cheaper than using a "fileicon" Icon for each file
+// if a workflow shows many files of the same type.
+//
+// If path has no extension or its type can't be determined, a "fileicon"
+// Icon for path is returned instead.
+func FileTypeIcon(path string) *Icon {
+	ext := strings.ToLower(filepath.Ext(path))
+	if ext == "" {
+		return &Icon{path, IconTypeFileIcon}
+	}
+
+	fileTypeIconsMu.Lock()
+	defer fileTypeIconsMu.Unlock()
+	if icon, ok := fileTypeIcons[ext]; ok {
+		return icon
+	}
+
+	uti, err := fileUTI(path)
+	if err != nil || uti == "" {
+		log.Printf("[warning] get type of %q: %v", path, err)
+		return &Icon{path, IconTypeFileIcon}
+	}
+	icon := &Icon{uti, IconTypeFileType}
+	fileTypeIcons[ext] = icon
+	return icon
+}
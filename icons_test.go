@@ -4,7 +4,13 @@
 package aw
 
 import (
+	"bytes"
+	"errors"
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -52,3 +58,79 @@ func TestIcons(t *testing.T) {
 		})
 	}
 }
+
+// Emoji icons are rendered once and then reused.
+func TestEmojiIcon(t *testing.T) {
+	orig := renderEmoji
+	defer func() { renderEmoji = orig }()
+
+	var calls int
+	renderEmoji = func(emoji, path string) error {
+		calls++
+		return ioutil.WriteFile(path, []byte(emoji), 0600)
+	}
+
+	withTestWf(func(wf *Workflow) {
+		icon := wf.EmojiIcon("🍺")
+		assert.NotNil(t, icon, "no icon")
+		assert.FileExists(t, icon.Value, "icon not created")
+		assert.Equal(t, IconTypeImage, icon.Type, "unexpected icon type")
+		assert.Equal(t, 1, calls, "emoji not rendered")
+
+		assert.Equal(t, icon, wf.EmojiIcon("🍺"), "different icon for same emoji")
+		assert.Equal(t, 1, calls, "emoji rendered again")
+
+		assert.NotEqual(t, icon, wf.EmojiIcon("🍷"), "same icon for different emoji")
+		assert.Equal(t, 2, calls, "emoji not rendered")
+	})
+}
+
+// Files with the same extension share an Icon.
+func TestFileTypeIcon(t *testing.T) {
+	orig := fileUTI
+	defer func() {
+		fileUTI = orig
+		fileTypeIcons = map[string]*Icon{}
+	}()
+
+	var calls int
+	fileUTI = func(path string) (string, error) {
+		calls++
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".pdf":
+			return "com.adobe.pdf", nil
+		case ".txt":
+			return "public.plain-text", nil
+		}
+		return "", errors.New("unknown type")
+	}
+
+	icon := FileTypeIcon("/path/to/one.pdf")
+	assert.Equal(t, &Icon{"com.adobe.pdf", IconTypeFileType}, icon, "unexpected icon")
+	assert.Same(t, icon, FileTypeIcon("/path/to/two.PDF"), "icon not reused")
+	assert.Equal(t, 1, calls, "type determined again")
+
+	assert.Equal(t, &Icon{"public.plain-text", IconTypeFileType}, FileTypeIcon("/notes.txt"), "unexpected icon")
+	assert.Equal(t, 2, calls, "type not determined")
+
+	// fall back to file icon
+	assert.Equal(t, &Icon{"/path/to/README", IconTypeFileIcon}, FileTypeIcon("/path/to/README"), "unexpected icon")
+	assert.Equal(t, &Icon{"/path/to/x.unknown", IconTypeFileIcon}, FileTypeIcon("/path/to/x.unknown"), "unexpected icon")
+	assert.Equal(t, 3, calls, "unexpected number of calls")
+}
+
+// Emoji icons are real PNG files.
+func TestEmojiIcon_render(t *testing.T) {
+	if runtime.GOOS != "darwin" {
+		t.Skip("emoji rendering requires macOS")
+	}
+
+	withTestWf(func(wf *Workflow) {
+		icon := wf.EmojiIcon("🍺")
+		if assert.NotNil(t, icon, "no icon") {
+			data, err := ioutil.ReadFile(icon.Value)
+			assert.Nil(t, err, "read icon")
+			assert.True(t, bytes.HasPrefix(data, []byte("\x89PNG")), "icon is not a PNG")
+		}
+	})
+}
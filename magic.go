@@ -44,6 +44,8 @@ default:
 	                    (usually Finder).
 	<prefix>cache       Open workflow's data directory in the default app
 	                    (usually Finder).
+	<prefix>folder      Open the workflow's own directory (where info.plist
+	                    is) in the default app (usually Finder).
 	<prefix>deldata     Delete everything in the workflow's data directory.
 	<prefix>delcache    Delete everything in the workflow's cache directory.
 	<prefix>reset       Delete everything in the workflow's data and cache directories.
@@ -199,6 +201,16 @@ func (a cacheMA) Description() string { return "Open workflow's cache directory"
 func (a cacheMA) RunText() string     { return "Opening cache directory…" }
 func (a cacheMA) Run() error          { return a.wf.OpenCache() }
 
+// Opens workflow's own directory.
+type folderMA struct {
+	wf *Workflow
+}
+
+func (a folderMA) Keyword() string     { return "folder" }
+func (a folderMA) Description() string { return "Open workflow's directory" }
+func (a folderMA) RunText() string     { return "Opening workflow directory…" }
+func (a folderMA) Run() error          { return a.wf.OpenDir() }
+
 // Deletes the contents of the workflow's cache directory.
 type clearCacheMA struct {
 	wf *Workflow
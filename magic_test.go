@@ -120,7 +120,7 @@ func TestMagicDefaults(t *testing.T) {
 		wf.Configure(HelpURL(helpURL))
 		ma := wf.magicActions
 
-		x := 7
+		x := 8
 		v := len(ma.actions)
 		if v != x {
 			t.Errorf("Bad MagicAction count. Expected=%d, Got=%d", x, v)
@@ -134,6 +134,7 @@ func TestMagicDefaults(t *testing.T) {
 			{"workflow:cache", "open", []string{"open", wf.CacheDir()}},
 			{"workflow:log", "open", []string{"open", wf.LogFile()}},
 			{"workflow:data", "open", []string{"open", wf.DataDir()}},
+			{"workflow:folder", "open", []string{"open", wf.Dir()}},
 			{"workflow:help", "open", []string{"open", helpURL}},
 		}
 
@@ -221,6 +222,45 @@ func TestMagicExits(t *testing.T) {
 	}
 }
 
+// Users can override the magic prefix via a workflow variable.
+func TestMagicPrefix_userVariable(t *testing.T) {
+	defer func() { exitFunc = os.Exit }()
+
+	tests := []struct {
+		prefix, in string
+		run        bool
+	}{
+		{"", "prefix:test", true},
+		{"", "aw:test", false},
+		{"aw:", "aw:test", true},
+		{"aw:", "prefix:test", false},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("%q/%q", td.prefix, td.in), func(t *testing.T) {
+			withTestEnv(func(e MapEnv) {
+				e[EnvVarMagicPrefix] = td.prefix
+				wf := NewFromEnv(e, MagicPrefix("prefix:"))
+				me := &mockExit{code: -1}
+				exitFunc = me.Exit
+				ta := &mockMA{}
+				wf.magicActions.register(ta)
+				wf.args = []string{td.in}
+
+				args := wf.Args()
+				if td.run {
+					assert.Nil(t, ta.ValidateRun(), "magic action not run")
+					assert.Equal(t, 0, me.code, "did not exit")
+				} else {
+					assert.NotNil(t, ta.ValidateRun(), "magic action run")
+					assert.Equal(t, []string{td.in}, args, "unexpected args")
+				}
+			})
+		})
+	}
+}
+
 // Test automatically-added updateMA.
 func TestMagicUpdate(t *testing.T) {
 	t.Parallel()
@@ -0,0 +1,58 @@
+// Copyright (c) 2019 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import "log"
+
+// MRU is a list of most-recently-used keys, e.g. recent queries or
+// selected items, which is persisted to a Cache. Adding a key that is
+// already in the list moves it to the front, and the oldest keys are
+// dropped when the list grows beyond its maximum size.
+type MRU struct {
+	cache *Cache
+	name  string
+	max   int
+	items []string
+}
+
+// NewMRU creates an MRU that holds up to max keys and is stored in cache
+// under name. Any previously-saved keys are loaded. If max is 0, the
+// list is unbounded.
+func NewMRU(cache *Cache, name string, max int) *MRU {
+	m := &MRU{cache: cache, name: name, max: max}
+	if cache.Exists(name) {
+		if err := cache.LoadJSON(name, &m.items); err != nil {
+			log.Printf("[warning] load MRU %q: %v", name, err)
+		}
+	}
+	m.trim()
+	return m
+}
+
+// Add moves key to the front of the list and saves the list to the cache.
+func (m *MRU) Add(key string) error {
+	items := []string{key}
+	for _, s := range m.items {
+		if s != key {
+			items = append(items, s)
+		}
+	}
+	m.items = items
+	m.trim()
+	return m.cache.StoreJSON(m.name, m.items)
+}
+
+// Items returns the keys, most-recently used first.
+func (m *MRU) Items() []string {
+	items := make([]string, len(m.items))
+	copy(items, m.items)
+	return items
+}
+
+// trim drops the oldest keys if there are more than max.
+func (m *MRU) trim() {
+	if m.max > 0 && len(m.items) > m.max {
+		m.items = m.items[:m.max]
+	}
+}
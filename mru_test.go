@@ -0,0 +1,38 @@
+// Copyright (c) 2019 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence - http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// MRU orders, dedupes, trims and persists keys.
+func TestMRU(t *testing.T) {
+	t.Parallel()
+
+	withTempDir(func(dir string) {
+		c := NewCache(dir)
+		m := NewMRU(c, "recent.json", 3)
+		assert.Equal(t, []string{}, m.Items(), "new MRU not empty")
+
+		for _, s := range []string{"one", "two", "three"} {
+			assert.Nil(t, m.Add(s), "add %q", s)
+		}
+		assert.Equal(t, []string{"three", "two", "one"}, m.Items(), "unexpected order")
+
+		// dedupe
+		assert.Nil(t, m.Add("one"), "add one")
+		assert.Equal(t, []string{"one", "three", "two"}, m.Items(), "unexpected order")
+
+		// trim
+		assert.Nil(t, m.Add("four"), "add four")
+		assert.Equal(t, []string{"four", "one", "three"}, m.Items(), "unexpected order")
+
+		// persistence
+		assert.Equal(t, []string{"four", "one", "three"}, NewMRU(c, "recent.json", 3).Items(), "MRU not saved")
+		assert.Equal(t, []string{"four", "one"}, NewMRU(c, "recent.json", 2).Items(), "MRU not trimmed")
+	})
+}
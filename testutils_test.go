@@ -9,9 +9,9 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
-	"go.deanishe.net/env"
 )
 
 var (
@@ -30,7 +30,7 @@ var (
 	tCacheDir                 = os.ExpandEnv("$HOME/Library/Caches/com.runningwithcrayons.Alfred/Workflow Data/net.deanishe.awgo")
 	tDataDir                  = os.ExpandEnv("$HOME/Library/Application Support/Alfred/Workflow Data/net.deanishe.awgo")
 
-	testEnv = env.MapEnv{
+	testEnv = MapEnv{
 		EnvVarVersion:          tVersion,
 		EnvVarName:             tName,
 		EnvVarBundleID:         tBundleID,
@@ -82,13 +82,54 @@ func withTempDir(fn func(dir string)) {
 	if p, err = filepath.EvalSymlinks(p); err != nil {
 		panic(err)
 	}
-	defer panicOnErr(os.RemoveAll(p))
+	defer func() { panicOnErr(os.RemoveAll(p)) }()
 	fn(p)
 }
 
+// fakeClock only moves forward when advanced.
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time      { return c.now }
+func (c *fakeClock) Add(d time.Duration) { c.now = c.now.Add(d) }
+
+// call function fn with timeNow replaced by a fake clock.
+// Tests that call this must not be run in parallel.
+func withFakeClock(fn func(c *fakeClock)) {
+	c := &fakeClock{now: time.Now()}
+	orig := timeNow
+	timeNow = c.Now
+	defer func() { timeNow = orig }()
+	fn(c)
+}
+
+// call function fn and return everything it writes to STDOUT.
+func captureStdout(fn func()) string {
+	r, w, err := os.Pipe()
+	if err != nil {
+		panic(err)
+	}
+
+	orig := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = orig }()
+
+	out := make(chan string)
+	go func() {
+		data, err := ioutil.ReadAll(r)
+		panicOnErr(err)
+		out <- string(data)
+	}()
+
+	fn()
+	panicOnErr(w.Close())
+	return <-out
+}
+
 // Call function with a test environment.
-func withTestEnv(fn func(e env.MapEnv)) {
-	e := env.MapEnv{
+func withTestEnv(fn func(e MapEnv)) {
+	e := MapEnv{
 		EnvVarVersion:          tVersion,
 		EnvVarName:             tName,
 		EnvVarBundleID:         tBundleID,
@@ -110,7 +151,7 @@ func withTestEnv(fn func(e env.MapEnv)) {
 
 // Call function in a test workflow environment.
 func withTestWf(fn func(wf *Workflow)) {
-	withTestEnv(func(e env.MapEnv) {
+	withTestEnv(func(e MapEnv) {
 		var (
 			dir string
 			err error
@@ -14,17 +14,65 @@ import (
 // Gitea is a Workflow Option. It sets a Workflow Updater for the specified Gitea repo.
 // Repo name should be the URL of the repo, e.g. "git.deanishe.net/deanishe/alfred-ssh".
 func Gitea(repo string) aw.Option {
-	return newOption(&source{URL: giteaURL(repo), fetch: getURL})
+	src := &source{URL: giteaURL(repo), fetch: getURL}
+	if src.URL == "" {
+		src.err = fmt.Errorf("invalid Gitea repo %q", repo)
+	}
+	return newOption(src)
+}
+
+// GiteaInstance is a Workflow Option. It sets a Workflow Updater for a repo
+// on the self-hosted Gitea (or Forgejo) instance at baseURL. Unlike Gitea,
+// it also supports instances that aren't at the root of their domain.
+// baseURL should be the URL of the instance's homepage, e.g.
+// "https://example.com/git", and repo should be of the form "username/repo".
+func GiteaInstance(baseURL, repo string) aw.Option {
+	src := &source{URL: giteaInstanceURL(baseURL, repo), fetch: getURL}
+	if src.URL == "" {
+		src.err = fmt.Errorf("invalid Gitea instance %q or repo %q", baseURL, repo)
+	}
+	return newOption(src)
 }
 
 func giteaURL(repo string) string {
-	if repo == "" {
+	u := parseBaseURL(repo)
+	if u == nil {
 		return ""
 	}
-	u, err := url.Parse(repo)
-	if err != nil {
+	path := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(path) != 2 {
 		return ""
 	}
+	u.Path = ""
+
+	return giteaInstanceURL(u.String(), path[0]+"/"+path[1])
+}
+
+func giteaInstanceURL(baseURL, repo string) string {
+	u := parseBaseURL(baseURL)
+	if u == nil {
+		return ""
+	}
+	path := strings.Split(strings.Trim(repo, "/"), "/")
+	if len(path) != 2 || path[0] == "" || path[1] == "" {
+		return ""
+	}
+
+	u.Path = strings.TrimRight(u.Path, "/") + fmt.Sprintf("/api/v1/repos/%s/%s/releases", path[0], path[1])
+
+	return u.String()
+}
+
+// parseBaseURL parses a URL that may lack a scheme. It returns nil if
+// the URL is invalid or has no host.
+func parseBaseURL(s string) *url.URL {
+	if s == "" {
+		return nil
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil
+	}
 	// If no scheme is specified, assume HTTPS and re-parse URL.
 	// This is necessary because URL.Host isn't present on URLs
 	// without a scheme (hostname is added to path)
@@ -32,18 +80,11 @@ func giteaURL(repo string) string {
 		u.Scheme = "https"
 		u, err = url.Parse(u.String())
 		if err != nil {
-			return ""
+			return nil
 		}
 	}
 	if u.Host == "" {
-		return ""
+		return nil
 	}
-	path := strings.Split(strings.Trim(u.Path, "/"), "/")
-	if len(path) != 2 {
-		return ""
-	}
-
-	u.Path = fmt.Sprintf("/api/v1/repos/%s/%s/releases", path[0], path[1])
-
-	return u.String()
+	return u
 }
@@ -9,6 +9,7 @@ import (
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	aw "github.com/deanishe/awgo"
 )
@@ -22,6 +23,7 @@ var testGiteaDownloads = []Download{
 		Filename:   "Dummy-10.0-beta.alfredworkflow",
 		Version:    mustVersion("v10.0-beta"),
 		Prerelease: true,
+		Size:       36063,
 	},
 	// Latest stable version for Alfred 4
 	{
@@ -29,6 +31,7 @@ var testGiteaDownloads = []Download{
 		Filename:   "Dummy-9.0.alfred4workflow",
 		Version:    mustVersion("v9.0"),
 		Prerelease: false,
+		Size:       36063,
 	},
 	// Latest version for Alfred 3
 	{
@@ -36,6 +39,7 @@ var testGiteaDownloads = []Download{
 		Filename:   "Dummy-7.1-beta.alfredworkflow",
 		Version:    mustVersion("v7.1.0-beta"),
 		Prerelease: true,
+		Size:       35726,
 	},
 	// Latest stable version for Alfred 3
 	{
@@ -43,30 +47,35 @@ var testGiteaDownloads = []Download{
 		Filename:   "Dummy-6.0.alfred4workflow",
 		Version:    mustVersion("v6.0"),
 		Prerelease: false,
+		Size:       36063,
 	},
 	{
 		URL:        "https://git.deanishe.net/attachments/eb86751a-7f31-49f0-be4c-1dd1e0557c9d",
 		Filename:   "Dummy-6.0.alfred3workflow",
 		Version:    mustVersion("v6.0"),
 		Prerelease: false,
+		Size:       36063,
 	},
 	{
 		URL:        "https://git.deanishe.net/attachments/61aa34a1-1877-4a41-ae50-01c18c8e2598",
 		Filename:   "Dummy-6.0.alfredworkflow",
 		Version:    mustVersion("v6.0"),
 		Prerelease: false,
+		Size:       36063,
 	},
 	{
 		URL:        "https://git.deanishe.net/attachments/03a01b52-93bc-48f0-9b09-37ba212a03fd",
 		Filename:   "Dummy-2.0.alfredworkflow",
 		Version:    mustVersion("v2.0"),
 		Prerelease: false,
+		Size:       36063,
 	},
 	{
 		URL:        "https://git.deanishe.net/attachments/d71ad702-cfce-46ba-aa26-2096d34ff97b",
 		Filename:   "Dummy-1.0.alfredworkflow",
 		Version:    mustVersion("v1.0"),
 		Prerelease: false,
+		Size:       36063,
 	},
 }
 
@@ -101,6 +110,54 @@ func TestGiteaURL(t *testing.T) {
 	}
 }
 
+func TestGiteaInstanceURL(t *testing.T) {
+	t.Parallel()
+
+	data := []struct {
+		base, repo string
+		url        string
+	}{
+		// Invalid input
+		{"", "deanishe/nonexistent", ""},
+		{"https://git.example.com", "", ""},
+		{"https://git.example.com", "deanishe", ""},
+		{"https://git.example.com", "deanishe/nonexistent/releases", ""},
+		{"/git", "deanishe/nonexistent", ""},
+		// Valid URLs
+		{"git.example.com", "deanishe/nonexistent", "https://git.example.com/api/v1/repos/deanishe/nonexistent/releases"},
+		{"https://git.example.com/", "deanishe/nonexistent", "https://git.example.com/api/v1/repos/deanishe/nonexistent/releases"},
+		{"http://git.example.com:3000", "/deanishe/nonexistent/", "http://git.example.com:3000/api/v1/repos/deanishe/nonexistent/releases"},
+		{"https://example.com/forgejo", "deanishe/nonexistent", "https://example.com/forgejo/api/v1/repos/deanishe/nonexistent/releases"},
+	}
+
+	for _, td := range data {
+		td := td
+		t.Run(td.base+" "+td.repo, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, td.url, giteaInstanceURL(td.base, td.repo), "unexpected URL")
+		})
+	}
+}
+
+// Releases are fetched from the custom instance.
+func TestGiteaInstance(t *testing.T) {
+	t.Parallel()
+
+	x := "https://example.com/forgejo/api/v1/repos/deanishe/alfred-workflow-dummy/releases"
+	src := &source{
+		URL: giteaInstanceURL("https://example.com/forgejo", "deanishe/alfred-workflow-dummy"),
+		fetch: func(URL string) ([]byte, error) {
+			if URL != x {
+				return nil, fmt.Errorf("unexpected URL: %s", URL)
+			}
+			return ioutil.ReadFile("testdata/gitea-releases.json")
+		},
+	}
+	dls, err := src.Downloads()
+	require.Nil(t, err, "fetch Gitea releases")
+	assert.Equal(t, testGiteaDownloads, dls, "unexpected downloads")
+}
+
 func TestGiteaUpdater(t *testing.T) {
 	t.Parallel()
 	src := &source{
@@ -21,17 +21,35 @@ var rxWorkflowFile = regexp.MustCompile(`\.alfred(\d+)?workflow$`)
 
 // GitHub is a Workflow Option. It sets a Workflow Updater for the specified GitHub repo.
 // Repo name should be of the form "username/repo", e.g. "deanishe/alfred-ssh".
+// If the repo has multiple pages of releases, all pages are retrieved.
 func GitHub(repo string) aw.Option {
-	return newOption(&source{
+	return newOption(gitHubSource(repo))
+}
+
+// matches a GitHub "username/repo" name
+var rxGitHubRepo = regexp.MustCompile(`^[a-zA-Z0-9-]+/[a-zA-Z0-9._-]+$`)
+
+// create Source for GitHub repo.
+func gitHubSource(repo string) *source {
+	src := &source{
 		URL:   "https://api.github.com/repos/" + repo + "/releases",
-		fetch: getURL,
-	})
+		fetch: getAllPages,
+	}
+	if !rxGitHubRepo.MatchString(repo) {
+		src.err = fmt.Errorf("invalid GitHub repo %q: must be of the form \"username/repo\"", repo)
+	}
+	return src
 }
 
-// create new Updater option from Source.
+// create new Updater option from Source. If the Source is invalid, the
+// error is logged and the workflow's Updater is left unchanged.
 func newOption(src Source) aw.Option {
 	return func(wf *aw.Workflow) aw.Option {
-		u, _ := NewUpdater(src, wf.Version(), filepath.Join(wf.CacheDir(), "_aw/update"))
+		u, err := NewUpdater(src, wf.Version(), filepath.Join(wf.CacheDir(), "_aw/update"))
+		if err != nil {
+			log.Printf("[error] create updater: %v", err)
+			return aw.Update(wf.CurrentUpdater())
+		}
 		return aw.Update(u)(wf)
 	}
 }
@@ -40,6 +58,18 @@ type source struct {
 	URL   string
 	dls   []Download
 	fetch func(URL string) ([]byte, error)
+	err   error // set if source is misconfigured
+}
+
+// Validate implements Validator.
+func (src *source) Validate() error {
+	if src.err != nil {
+		return src.err
+	}
+	if src.URL == "" {
+		return errors.New("no URL")
+	}
+	return nil
 }
 
 // Downloads implements Source.
@@ -60,7 +90,8 @@ func (src *source) Downloads() ([]Download, error) {
 	return src.dls, nil
 }
 
-// parse GitHub/Gitea releases JSON.
+// parse GitHub/Gitea releases JSON. Download versions are taken from
+// release tags, so asset filenames needn't contain a version number.
 func parseReleases(js []byte) ([]Download, error) {
 	var (
 		dls  = []Download{}
@@ -70,6 +101,7 @@ func parseReleases(js []byte) ([]Download, error) {
 			Assets     []struct {
 				Name             string `json:"name"`
 				URL              string `json:"browser_download_url"`
+				Size             int64  `json:"size"`
 				MinAlfredVersion SemVer `json:"-"`
 			} `json:"assets"`
 			Tag string `json:"tag_name"`
@@ -100,6 +132,7 @@ func parseReleases(js []byte) ([]Download, error) {
 				Filename:   a.Name,
 				Version:    v,
 				Prerelease: r.Prerelease,
+				Size:       a.Size,
 			}
 			all = append(all, w)
 		}
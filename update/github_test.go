@@ -4,8 +4,12 @@
 package update
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
 	"testing"
 
 	aw "github.com/deanishe/awgo"
@@ -22,6 +26,7 @@ var testGitHubDownloads = []Download{
 		Filename:   "Dummy-10.0-beta.alfredworkflow",
 		Version:    mustVersion("v10.0-beta"),
 		Prerelease: true,
+		Size:       36063,
 	},
 	// Latest stable version for Alfred 4
 	{
@@ -29,6 +34,7 @@ var testGitHubDownloads = []Download{
 		Filename:   "Dummy-9.0.alfred4workflow",
 		Version:    mustVersion("v9.0"),
 		Prerelease: false,
+		Size:       36063,
 	},
 	// Latest version for Alfred 3
 	{
@@ -36,6 +42,7 @@ var testGitHubDownloads = []Download{
 		Filename:   "Dummy-7.1-beta.alfredworkflow",
 		Version:    mustVersion("v7.1.0-beta"),
 		Prerelease: true,
+		Size:       35726,
 	},
 	// Latest stable version for Alfred 3
 	{
@@ -43,30 +50,35 @@ var testGitHubDownloads = []Download{
 		Filename:   "Dummy-6.0.alfred4workflow",
 		Version:    mustVersion("v6.0"),
 		Prerelease: false,
+		Size:       36063,
 	},
 	{
 		URL:        "https://github.com/deanishe/alfred-workflow-dummy/releases/download/v6.0/Dummy-6.0.alfred3workflow",
 		Filename:   "Dummy-6.0.alfred3workflow",
 		Version:    mustVersion("v6.0"),
 		Prerelease: false,
+		Size:       36063,
 	},
 	{
 		URL:        "https://github.com/deanishe/alfred-workflow-dummy/releases/download/v6.0/Dummy-6.0.alfredworkflow",
 		Filename:   "Dummy-6.0.alfredworkflow",
 		Version:    mustVersion("v6.0"),
 		Prerelease: false,
+		Size:       36063,
 	},
 	{
 		URL:        "https://github.com/deanishe/alfred-workflow-dummy/releases/download/v2.0/Dummy-2.0.alfredworkflow",
 		Filename:   "Dummy-2.0.alfredworkflow",
 		Version:    mustVersion("v2.0"),
 		Prerelease: false,
+		Size:       36063,
 	},
 	{
 		URL:        "https://github.com/deanishe/alfred-workflow-dummy/releases/download/v1.0/Dummy-1.0.alfredworkflow",
 		Filename:   "Dummy-1.0.alfredworkflow",
 		Version:    mustVersion("v1.0"),
 		Prerelease: false,
+		Size:       36063,
 	},
 }
 
@@ -75,6 +87,26 @@ func TestParseGitHub(t *testing.T) {
 	testParseReleases("GitHub", "testdata/github-releases.json", testGitHubDownloads, t)
 }
 
+// Version is read from the release tag, not the asset's filename.
+func TestParseGitHub_unversionedAssets(t *testing.T) {
+	t.Parallel()
+	x := []Download{
+		{
+			URL:      "https://github.com/deanishe/alfred-workflow-dummy/releases/download/v3.2.1/MyWorkflow.alfredworkflow",
+			Filename: "MyWorkflow.alfredworkflow",
+			Version:  mustVersion("v3.2.1"),
+			Size:     36063,
+		},
+		{
+			URL:      "https://github.com/deanishe/alfred-workflow-dummy/releases/download/v3.1/MyWorkflow.alfredworkflow",
+			Filename: "MyWorkflow.alfredworkflow",
+			Version:  mustVersion("v3.1"),
+			Size:     35726,
+		},
+	}
+	testParseReleases("GitHub", "testdata/github-releases-unversioned.json", x, t)
+}
+
 func testParseReleases(name, jsonPath string, downloads []Download, t *testing.T) {
 	t.Run(name+"parse empty releases", func(t *testing.T) {
 		t.Parallel()
@@ -113,6 +145,56 @@ func TestGitHubUpdater(t *testing.T) {
 	testSourceUpdater("GitHub", src, t)
 }
 
+// All pages of releases are fetched and combined.
+func TestGitHub_pagination(t *testing.T) {
+	t.Parallel()
+
+	data, err := ioutil.ReadFile("testdata/github-releases.json")
+	require.Nil(t, err, "read releases")
+	var rels []json.RawMessage
+	require.Nil(t, json.Unmarshal(data, &rels), "unmarshal releases")
+	var (
+		n     = len(rels) / 2
+		pages = [][]json.RawMessage{rels[:n], rels[n:]}
+		hits  int32
+	)
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		page := 0
+		if r.URL.Query().Get("page") == "2" {
+			page = 1
+		} else {
+			w.Header().Set("Link", fmt.Sprintf(`<%s/releases?page=2>; rel="next", <%[1]s/releases?page=2>; rel="last"`, ts.URL))
+		}
+		panicOnError(json.NewEncoder(w).Encode(pages[page]))
+	}))
+	defer ts.Close()
+
+	src := &source{URL: ts.URL + "/releases", fetch: getAllPages}
+	dls, err := src.Downloads()
+	require.Nil(t, err, "fetch releases")
+	assert.Equal(t, int32(2), atomic.LoadInt32(&hits), "unexpected request count")
+	assert.Equal(t, testGitHubDownloads, dls, "unexpected downloads")
+}
+
+// NewUpdater rejects invalid GitHub repo names.
+func TestGitHub_invalidRepo(t *testing.T) {
+	t.Parallel()
+
+	withTempDir(func(dir string) {
+		for _, repo := range []string{"deanishe/alfred-ssh", "deanishe/awgo.go"} {
+			_, err := NewUpdater(gitHubSource(repo), "1.0", dir)
+			assert.Nil(t, err, "rejected valid repo %q", repo)
+		}
+		for _, repo := range []string{"", "deanishe", "deanishe/alfred-ssh/", "github.com/deanishe/awgo", "dean ishe/awgo"} {
+			_, err := NewUpdater(gitHubSource(repo), "1.0", dir)
+			assert.NotNil(t, err, "accepted invalid repo %q", repo)
+		}
+	})
+}
+
 func testSourceUpdater(name string, src *source, t *testing.T) {
 	withTempDir(func(dir string) {
 		dls, err := src.Downloads()
@@ -20,13 +20,7 @@ import (
 // set `downloadurl` in the `metadata.json` file to the URL
 // of your .alfredworkflow (or .alfred4workflow etc.) file.
 func Metadata(url string) aw.Option {
-	return func(wf *aw.Workflow) aw.Option {
-		u, _ := NewUpdater(&metadataSource{url: url, fetch: getURL},
-			wf.Version(),
-			filepath.Join(wf.CacheDir(), "_aw/update"),
-		)
-		return aw.Update(u)(wf)
-	}
+	return newOption(&metadataSource{url: url, fetch: getURL})
 }
 
 type metadataSource struct {
@@ -35,6 +29,18 @@ type metadataSource struct {
 	fetch func(URL string) ([]byte, error)
 }
 
+// Validate implements Validator.
+func (src *metadataSource) Validate() error {
+	u, err := url.Parse(src.url)
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("invalid metadata URL %q", src.url)
+	}
+	return nil
+}
+
 // Downloads implements Source.
 func (src *metadataSource) Downloads() ([]Download, error) {
 	if src.dl == nil {
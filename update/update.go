@@ -4,6 +4,7 @@
 package update
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -15,7 +16,9 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"sort"
+	"strings"
 	"time"
 
 	"github.com/deanishe/awgo/util"
@@ -27,19 +30,29 @@ var (
 	// HTTPTimeout is the timeout for establishing an HTTP(S) connection.
 	HTTPTimeout = 60 * time.Second
 
-	// HTTP client used to talk to APIs
+	// HTTP client used to talk to APIs. Set with SetHTTPClient.
 	client *http.Client
 )
 
+// SetHTTPClient sets the http.Client used to fetch release lists and
+// download updates, e.g. to use a proxy or custom TLS configuration.
+// If c is nil, a default client configured with HTTPTimeout is used.
+//
+// It is not safe to call SetHTTPClient while an Updater is in use.
+func SetHTTPClient(c *http.Client) { client = c }
+
 // Mockable functions
 var (
 	// Run command
 	runCommand = func(name string, arg ...string) error {
 		return exec.Command(name, arg...).Run()
 	}
-	// save a URL to a filepath.
-	download = func(URL, path string) error {
-		res, err := openURL(URL)
+	// Current time. Used to calculate when update checks are due.
+	timeNow = time.Now
+	// save a URL to a filepath. progress may be nil. The file is
+	// deleted if the download fails or ctx is cancelled.
+	download = func(ctx context.Context, URL, path string, progress ProgressFunc) error {
+		res, err := openURLContext(ctx, URL)
 		if err != nil {
 			return err
 		}
@@ -50,9 +63,17 @@ var (
 		if err != nil {
 			return err
 		}
-		defer out.Close()
-		n, err := io.Copy(out, res.Body)
+		var r io.Reader = res.Body
+		if progress != nil {
+			r = io.TeeReader(r, &progressWriter{total: res.ContentLength, fn: progress})
+		}
+		// a body shorter than its Content-Length fails with io.ErrUnexpectedEOF
+		n, err := io.Copy(out, r)
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
 		if err != nil {
+			_ = os.Remove(path)
 			return err
 		}
 		log.Printf("wrote %q (%d bytes)", util.PrettyPath(path), n)
@@ -60,14 +81,46 @@ var (
 	}
 )
 
+// ProgressFunc is called as an update is downloaded with the number of
+// bytes received so far and the total size of the download, which is -1
+// if unknown.
+type ProgressFunc func(done, total int64)
+
+// progressWriter passes the running count of bytes written to a ProgressFunc.
+type progressWriter struct {
+	done  int64
+	total int64
+	fn    ProgressFunc
+}
+
+// Write implements io.Writer.
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.done += int64(len(p))
+	w.fn(w.done, w.total)
+	return len(p), nil
+}
+
 // Source provides workflow files that can be downloaded.
 // This is what concrete updaters (e.g. GitHub, Gitea) should implement.
 // Source is called by the Updater after every updater interval.
+//
+// Sources may also implement Validator to report misconfiguration
+// (e.g. an invalid repo name) when the Updater is created.
 type Source interface {
 	// Downloads returns all available workflow files.
 	Downloads() ([]Download, error)
 }
 
+// Validator is implemented by Sources that can check their configuration.
+// NewUpdater calls Validate and returns its error.
+type Validator interface {
+	Validate() error
+}
+
+// ChannelStable is the release channel of downloads that aren't pre-releases.
+// Set Updater.Channel to ChannelStable to ignore all pre-releases.
+const ChannelStable = "stable"
+
 // byVersion sorts downloads by version.
 type byVersion []Download
 
@@ -92,6 +145,7 @@ type Download struct {
 	Filename   string
 	Version    SemVer // Semantic version no.
 	Prerelease bool   // Whether this version is a pre-release
+	Size       int64  // Size of file in bytes; 0 if unknown
 }
 
 // AlfredVersion returns minimum compatible version of Alfred based on file extension.
@@ -108,6 +162,22 @@ func (dl Download) AlfredVersion() SemVer {
 	return SemVer{}
 }
 
+// Channel returns the release channel of the download, which is derived
+// from its version's pre-release identifier, e.g. "beta" for "v1.0-beta.2"
+// or "rc" for "v2.1-rc1". Downloads that are not pre-releases are on
+// ChannelStable. If a pre-release has no alphabetic identifier, Channel
+// returns an empty string.
+func (dl Download) Channel() string {
+	pre := dl.Version.Prerelease
+	if pre == "" && !dl.Prerelease {
+		return ChannelStable
+	}
+	if i := strings.IndexAny(pre, ".-0123456789"); i != -1 {
+		pre = pre[:i]
+	}
+	return strings.ToLower(pre)
+}
+
 // Updater checks for newer version of the workflow. Available versions are
 // provided by a Source, such as the built-in GitHub source, which
 // reads the releases in a GitHub repo. It is a concrete implementation
@@ -130,12 +200,29 @@ func (dl Download) AlfredVersion() SemVer {
 type Updater struct {
 	Source         Source // Provides downloads
 	CurrentVersion SemVer // Version of the installed workflow
-	Prereleases    bool   // Include pre-releases when checking for updates
+	// Include pre-releases when checking for updates.
+	// CheckForUpdate caches all releases, and pre-releases are filtered
+	// out when the cache is read, so changing Prereleases immediately
+	// affects UpdateAvailable and Install without another check.
+	Prereleases bool
+
+	// Channel selects the release track, e.g. "beta" or "alpha".
+	// If set, Prereleases is ignored, and only stable releases and
+	// pre-releases on the same channel are considered. Set it to
+	// ChannelStable to ignore all pre-releases. See Download.Channel()
+	// for how channels are determined.
+	Channel string
 
 	// AlfredVersion is the version of the running Alfred application.
 	// Read from $alfred_version environment variable.
 	AlfredVersion SemVer
 
+	// Progress, if set, is called repeatedly by Install while the update
+	// is being downloaded. As Install is usually run in a background
+	// process, a workflow might use it to cache the progress for a
+	// Script Filter (set to rerun) to display.
+	Progress ProgressFunc
+
 	// When the remote release list was last checked (and possibly cached)
 	LastCheck      time.Time
 	updateInterval time.Duration // How often to check for an update
@@ -158,6 +245,11 @@ func NewUpdater(src Source, currentVersion, cacheDir string) (*Updater, error) {
 	if cacheDir == "" {
 		return nil, errors.New("empty cacheDir")
 	}
+	if val, ok := src.(Validator); ok {
+		if err := val.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid source: %w", err)
+		}
+	}
 
 	u := &Updater{
 		CurrentVersion: v,
@@ -206,16 +298,17 @@ func (u *Updater) CheckDue() bool {
 		// log.Println("never checked for updates")
 		return true
 	}
-	elapsed := time.Since(u.LastCheck)
+	elapsed := timeNow().Sub(u.LastCheck)
 	log.Printf("%s since last check for update", elapsed)
 	return elapsed > u.updateInterval
 }
 
 // CheckForUpdate fetches the list of releases from remote (via Releaser)
-// and caches it locally.
+// and caches it locally. All releases are cached regardless of the
+// Prereleases and Channel settings.
 func (u *Updater) CheckForUpdate() error {
 	// If update fails, don't try again for at least an hour
-	u.LastCheck = time.Now().Add(-u.updateInterval).Add(time.Hour)
+	u.LastCheck = timeNow().Add(-u.updateInterval).Add(time.Hour)
 	defer u.cacheLastCheck()
 
 	var (
@@ -227,6 +320,10 @@ func (u *Updater) CheckForUpdate() error {
 	if dls, err = u.Source.Downloads(); err != nil {
 		return err
 	}
+	// Sort newest first and, within a version, by required Alfred version
+	// (highest first), so latest() picks the most specific compatible file.
+	dls = append([]Download{}, dls...)
+	sort.Sort(sort.Reverse(byVersion(dls)))
 	u.downloads = dls
 	if data, err = json.Marshal(dls); err != nil {
 		return err
@@ -236,27 +333,52 @@ func (u *Updater) CheckForUpdate() error {
 	if err := ioutil.WriteFile(u.pathDownloads, data, 0600); err != nil {
 		return err
 	}
-	u.LastCheck = time.Now()
+	u.LastCheck = timeNow()
 	return nil
 }
 
 // Install downloads and installs the latest available version.
 // After the workflow file is downloaded, Install calls Alfred to
-// install the update.
-func (u *Updater) Install() error {
+// install the update. If the Download's Size is known, Install fails
+// if the downloaded file is a different size.
+func (u *Updater) Install() error { return u.InstallContext(context.Background()) }
+
+// InstallContext is like Install, but the download is cancelled if ctx
+// is done, in which case the partially-downloaded file is deleted.
+func (u *Updater) InstallContext(ctx context.Context) error {
 	dl := u.latest()
 	if dl == nil {
 		return errors.New("no downloads available")
 	}
 	log.Printf("downloading version %s ...", dl.Version)
 	p := filepath.Join(u.cacheDir, dl.Filename)
-	if err := download(dl.URL, p); err != nil {
+	if err := download(ctx, dl.URL, p, u.Progress); err != nil {
+		return err
+	}
+	if err := checkSize(p, dl.Size); err != nil {
+		_ = os.Remove(p)
 		return err
 	}
 
 	return runCommand("open", p)
 }
 
+// checkSize returns an error if file at path isn't size bytes long.
+// It does nothing if size is 0, i.e. unknown.
+func checkSize(path string, size int64) error {
+	if size == 0 {
+		return nil
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if fi.Size() != size {
+		return fmt.Errorf("downloaded file %q is %d bytes, expected %d", filepath.Base(path), fi.Size(), size)
+	}
+	return nil
+}
+
 // clearCache removes the update cache.
 func (u *Updater) clearCache() {
 	if err := util.ClearDirectory(u.cacheDir); err != nil {
@@ -278,7 +400,10 @@ func (u *Updater) cacheLastCheck() {
 }
 
 // Returns latest version that is compatible with the Updater's
-// Alfred version & pre-release preference.
+// Alfred version & pre-release preference. If a release has several
+// workflow files, e.g. Workflow.alfredworkflow and Workflow.alfred4workflow,
+// the one for the highest Alfred version not newer than the running one
+// is chosen.
 func (u *Updater) latest() *Download {
 	if u.downloads == nil {
 		u.downloads = []Download{}
@@ -303,7 +428,7 @@ func (u *Updater) latest() *Download {
 	}
 	for _, dl := range u.downloads {
 		dl := dl
-		if dl.Prerelease && !u.Prereleases {
+		if !u.inChannel(dl) {
 			continue
 		}
 		if !u.AlfredVersion.IsZero() && dl.AlfredVersion().Gt(u.AlfredVersion) {
@@ -315,6 +440,16 @@ func (u *Updater) latest() *Download {
 	return nil
 }
 
+// inChannel returns true if download is on one of the Updater's release
+// channels/matches its pre-release preference.
+func (u *Updater) inChannel(dl Download) bool {
+	if u.Channel == "" {
+		return !dl.Prerelease || u.Prereleases
+	}
+	ch := dl.Channel()
+	return ch == ChannelStable || ch == strings.ToLower(u.Channel)
+}
+
 // // Mockable function to run commands
 // type commandRunner func(name string, arg ...string) error
 //
@@ -348,14 +483,84 @@ func getURL(url string) ([]byte, error) {
 	return ioutil.ReadAll(res.Body)
 }
 
+// maxPages is the maximum number of pages fetchPages retrieves.
+const maxPages = 10
+
+// fetchPages retrieves URL and subsequent pages of a paginated API.
+// fetch retrieves a page, and next extracts the URL of the following page
+// from its response, returning an empty string if it is the last one.
+// It returns the bodies of all pages in order.
+func fetchPages(URL string, fetch func(URL string) (*http.Response, error), next func(r *http.Response) string) ([][]byte, error) {
+	var pages [][]byte
+	for URL != "" {
+		if len(pages) == maxPages {
+			log.Printf("[warning] stopped after %d pages", maxPages)
+			break
+		}
+		r, err := fetch(URL)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		pages = append(pages, data)
+		URL = next(r)
+	}
+	return pages, nil
+}
+
+// rxNextLink matches the URL of the next page in a Link header.
+var rxNextLink = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// nextLink returns the URL of the next page from the response's Link
+// header, as used by GitHub and Gitea.
+func nextLink(r *http.Response) string {
+	for _, s := range r.Header["Link"] {
+		if m := rxNextLink.FindStringSubmatch(s); m != nil {
+			return m[1]
+		}
+	}
+	return ""
+}
+
+// getAllPages fetches all pages of a paginated API whose responses are
+// JSON arrays, and combines them into a single JSON array.
+func getAllPages(URL string) ([]byte, error) {
+	pages, err := fetchPages(URL, openURL, nextLink)
+	if err != nil {
+		return nil, err
+	}
+	all := []json.RawMessage{}
+	for i, data := range pages {
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return nil, fmt.Errorf("page %d: %w", i+1, err)
+		}
+		all = append(all, items...)
+	}
+	return json.Marshal(all)
+}
+
 // openURL returns an http.Response. It will return an error if the
 // HTTP status code > 299.
 func openURL(url string) (*http.Response, error) {
+	return openURLContext(context.Background(), url)
+}
+
+// openURLContext is like openURL, but the request is cancelled if ctx is done.
+func openURLContext(ctx context.Context, url string) (*http.Response, error) {
 	log.Printf("fetching %s ...", url)
 	if client == nil {
 		client = makeHTTPClient()
 	}
-	r, err := client.Get(url)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	r, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
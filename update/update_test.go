@@ -4,17 +4,24 @@
 package update
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/deanishe/awgo/util"
 )
 
 // Mock exec.Command
@@ -78,6 +85,13 @@ var (
 			{Version: mustVersion("0.3.0-beta"), Prerelease: true, Filename: "Dummy.alfredworkflow"},
 		},
 	}
+	testSrc3 = &testSource{
+		dls: []Download{
+			{Version: mustVersion("0.7.0-alpha.1"), Prerelease: true, Filename: "Dummy.alfredworkflow"},
+			{Version: mustVersion("0.6.0-beta.2"), Prerelease: true, Filename: "Dummy.alfredworkflow"},
+			{Version: mustVersion("0.5.0"), Prerelease: false, Filename: "Dummy.alfredworkflow"},
+		},
+	}
 )
 
 func TestUpdater(t *testing.T) {
@@ -129,6 +143,180 @@ func TestUpdaterPreOnly(t *testing.T) {
 	})
 }
 
+// Toggling Prereleases takes effect without a new check.
+func TestUpdaterPrereleaseToggle(t *testing.T) {
+	t.Parallel()
+
+	withTempDir(func(dir string) {
+		u, err := NewUpdater(testSrc1, "0.4.5", dir)
+		require.Nil(t, err, "create updater failed")
+		require.Nil(t, u.CheckForUpdate(), "get releases failed")
+		lastCheck := u.LastCheck
+
+		assert.False(t, u.UpdateAvailable(), "unexpected update")
+		u.Prereleases = true
+		assert.True(t, u.UpdateAvailable(), "pre-release not available")
+		u.Prereleases = false
+		assert.False(t, u.UpdateAvailable(), "pre-release still available")
+
+		// new Updater reading cached releases, i.e. next run
+		u, err = NewUpdater(&testSource{}, "0.4.5", dir)
+		require.Nil(t, err, "create updater failed")
+		assert.False(t, u.CheckDue(), "check due after toggle")
+		assert.True(t, lastCheck.Equal(u.LastCheck), "LastCheck changed")
+		assert.False(t, u.UpdateAvailable(), "unexpected update from cache")
+		u.Prereleases = true
+		assert.True(t, u.UpdateAvailable(), "cached pre-release not available")
+		assert.Equal(t, "0.5.0-beta", u.latest().Version.String(), "unexpected version")
+	})
+}
+
+// TestUpdaterChannel tests selection of pre-releases by channel.
+func TestUpdaterChannel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		channel string
+		pre     bool
+		x       string
+	}{
+		{"", false, "0.5.0"},
+		{"", true, "0.7.0-alpha.1"},
+		{ChannelStable, true, "0.5.0"},
+		{"beta", false, "0.6.0-beta.2"},
+		{"Beta", false, "0.6.0-beta.2"},
+		{"alpha", false, "0.7.0-alpha.1"},
+		{"rc", true, "0.5.0"},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("channel=%q, prereleases=%v", td.channel, td.pre), func(t *testing.T) {
+			t.Parallel()
+			withTempDir(func(dir string) {
+				u, err := NewUpdater(testSrc3, "0.4.0", dir)
+				require.Nil(t, err, "create updater failed")
+				require.Nil(t, u.CheckForUpdate(), "get releases failed")
+
+				u.Channel = td.channel
+				u.Prereleases = td.pre
+				dl := u.latest()
+				require.NotNil(t, dl, "no download")
+				assert.Equal(t, td.x, dl.Version.String(), "unexpected version")
+			})
+		})
+	}
+}
+
+// TestUpdater_alfredSpecificFile tests that the workflow file for the
+// running Alfred version is preferred over generic ones.
+func TestUpdater_alfredSpecificFile(t *testing.T) {
+	t.Parallel()
+
+	v := mustVersion("1.0.0")
+	tests := []struct {
+		alfred string
+		dls    []Download
+		x      string
+	}{
+		{"4.0", []Download{
+			{Version: v, Filename: "Dummy.alfredworkflow"},
+			{Version: v, Filename: "Dummy.alfred4workflow"},
+			{Version: v, Filename: "Dummy.alfred3workflow"},
+		}, "Dummy.alfred4workflow"},
+		{"3.8.1", []Download{
+			{Version: v, Filename: "Dummy.alfred4workflow"},
+			{Version: v, Filename: "Dummy.alfredworkflow"},
+			{Version: v, Filename: "Dummy.alfred3workflow"},
+		}, "Dummy.alfred3workflow"},
+		{"3.8.1", []Download{
+			{Version: v, Filename: "Dummy.alfred4workflow"},
+			{Version: v, Filename: "Dummy.alfredworkflow"},
+		}, "Dummy.alfredworkflow"},
+	}
+
+	for i, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("%d: Alfred %s", i, td.alfred), func(t *testing.T) {
+			t.Parallel()
+			withTempDir(func(dir string) {
+				u, err := NewUpdater(&testSource{dls: td.dls}, "0.1.0", dir)
+				require.Nil(t, err, "create updater failed")
+				u.AlfredVersion = mustVersion(td.alfred)
+				require.Nil(t, u.CheckForUpdate(), "get releases failed")
+
+				dl := u.latest()
+				require.NotNil(t, dl, "no download")
+				assert.Equal(t, td.x, dl.Filename, "unexpected file")
+			})
+		})
+	}
+}
+
+func TestDownload_Channel(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		v   string
+		pre bool
+		x   string
+	}{
+		{"1.0", false, ChannelStable},
+		{"1.0-beta", true, "beta"},
+		{"1.0-beta.2", true, "beta"},
+		{"1.0-RC1", true, "rc"},
+		{"1.0-alpha", false, "alpha"},
+		{"1.0", true, ""},
+		{"1.0-1", true, ""},
+	}
+
+	for _, td := range tests {
+		dl := Download{Version: mustVersion(td.v), Prerelease: td.pre}
+		assert.Equal(t, td.x, dl.Channel(), "unexpected channel for %q", td.v)
+	}
+}
+
+// recordingTransport records requested URLs and responds with data.
+type recordingTransport struct {
+	mu   sync.Mutex
+	urls []string
+	data []byte
+}
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.mu.Lock()
+	rt.urls = append(rt.urls, req.URL.String())
+	rt.mu.Unlock()
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Status:     "200 OK",
+		Header:     http.Header{},
+		Body:       ioutil.NopCloser(bytes.NewReader(rt.data)),
+		Request:    req,
+	}, nil
+}
+
+// CheckForUpdate uses the client set with SetHTTPClient.
+// Replaces the global client, so can't run in parallel.
+func TestSetHTTPClient(t *testing.T) {
+	orig := client
+	defer SetHTTPClient(orig)
+
+	rt := &recordingTransport{data: mustRead("testdata/github-releases.json")}
+	SetHTTPClient(&http.Client{Transport: rt})
+
+	withTempDir(func(dir string) {
+		u, err := NewUpdater(gitHubSource("deanishe/alfred-ssh"), "0.1", dir)
+		require.Nil(t, err, "create updater failed")
+		require.Nil(t, u.CheckForUpdate(), "check for update failed")
+		assert.Equal(t, []string{"https://api.github.com/repos/deanishe/alfred-ssh/releases"}, rt.urls, "unexpected requests")
+		assert.True(t, u.UpdateAvailable(), "no update available")
+	})
+
+	SetHTTPClient(nil)
+	require.Nil(t, client, "client not reset")
+}
+
 // TestUpdateInterval tests caching of LastCheck.
 func TestUpdateInterval(t *testing.T) {
 	t.Parallel()
@@ -142,6 +330,33 @@ func TestUpdateInterval(t *testing.T) {
 	})
 }
 
+// Update checks are due according to (mockable) clock.
+func TestCheckDue_clock(t *testing.T) {
+	origNow := timeNow
+	defer func() { timeNow = origNow }()
+	now := time.Now()
+	timeNow = func() time.Time { return now }
+
+	withTempDir(func(dir string) {
+		u, err := NewUpdater(testSrc1, "0.2.2", dir)
+		require.Nil(t, err, "create updater failed")
+		require.Nil(t, u.CheckForUpdate(), "get releases failed")
+		assert.False(t, u.CheckDue(), "update check is due")
+
+		now = now.Add(UpdateInterval - time.Minute)
+		assert.False(t, u.CheckDue(), "update check due early")
+		now = now.Add(2 * time.Minute)
+		assert.True(t, u.CheckDue(), "update check is not due")
+
+		// failed checks are retried after an hour
+		u.Source = testFailSource{}
+		require.NotNil(t, u.CheckForUpdate(), "failing check succeeded")
+		assert.False(t, u.CheckDue(), "update check is due after failure")
+		now = now.Add(time.Hour + time.Minute)
+		assert.True(t, u.CheckDue(), "update check is not due an hour after failure")
+	})
+}
+
 func testUpdateInterval(src Source, fail bool, t *testing.T) {
 	withTempDir(func(dir string) {
 		u, err := NewUpdater(src, "0.2.2", dir)
@@ -176,7 +391,7 @@ func TestUpdater_Install(t *testing.T) {
 
 	me := &mockExec{}
 	runCommand = me.Run
-	download = func(URL, path string) error { return nil }
+	download = func(_ context.Context, URL, path string, _ ProgressFunc) error { return nil }
 
 	withTempDir(func(dir string) {
 		u, err := NewUpdater(testSrc1, "0.2.2", dir)
@@ -190,6 +405,171 @@ func TestUpdater_Install(t *testing.T) {
 	})
 }
 
+// Install fails if the downloaded file is not the size given by Download.
+func TestUpdater_Install_size(t *testing.T) {
+	origRun := runCommand
+	defer func() { runCommand = origRun }()
+
+	me := &mockExec{}
+	runCommand = me.Run
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := fmt.Fprint(w, "contents"); err != nil {
+			panic(err)
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		size int64
+		ok   bool
+	}{
+		{0, true},
+		{8, true},
+		{1024, false},
+	}
+
+	for _, td := range tests {
+		withTempDir(func(dir string) {
+			src := &testSource{dls: []Download{
+				{URL: ts.URL, Filename: "Dummy.alfredworkflow", Version: mustVersion("0.5"), Size: td.size},
+			}}
+			u, err := NewUpdater(src, "0.2.2", dir)
+			require.Nil(t, err, "create updater failed")
+			require.Nil(t, u.CheckForUpdate(), "get releases failed")
+
+			me.name = ""
+			err = u.Install()
+			if td.ok {
+				assert.Nil(t, err, "install failed (size=%d)", td.size)
+				assert.Equal(t, "open", me.name, "workflow not opened (size=%d)", td.size)
+			} else {
+				assert.NotNil(t, err, "truncated download installed (size=%d)", td.size)
+				assert.Equal(t, "", me.name, "truncated workflow opened (size=%d)", td.size)
+			}
+		})
+	}
+}
+
+// Install fails if the download is truncated, i.e. the connection closes
+// before the whole body is received.
+func TestUpdater_Install_truncated(t *testing.T) {
+	origRun := runCommand
+	defer func() { runCommand = origRun }()
+
+	me := &mockExec{}
+	runCommand = me.Run
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "1024")
+		if _, err := fmt.Fprint(w, "contents"); err != nil {
+			panic(err)
+		}
+		// server closes connection after handler returns without writing
+		// Content-Length bytes
+	}))
+	defer ts.Close()
+
+	withTempDir(func(dir string) {
+		src := &testSource{dls: []Download{
+			{URL: ts.URL, Filename: "Dummy.alfredworkflow", Version: mustVersion("0.5")},
+		}}
+		u, err := NewUpdater(src, "0.2.2", dir)
+		require.Nil(t, err, "create updater failed")
+		require.Nil(t, u.CheckForUpdate(), "get releases failed")
+
+		err = u.Install()
+		assert.NotNil(t, err, "truncated download installed")
+		assert.True(t, errors.Is(err, io.ErrUnexpectedEOF), "unexpected error: %v", err)
+		assert.Equal(t, "", me.name, "truncated workflow opened")
+	})
+}
+
+// Install reports download progress.
+func TestUpdater_Install_progress(t *testing.T) {
+	origRun := runCommand
+	defer func() { runCommand = origRun }()
+	runCommand = (&mockExec{}).Run
+
+	const size = 64 * 1024
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+		chunk := make([]byte, 8*1024)
+		for i := 0; i < size/len(chunk); i++ {
+			if _, err := w.Write(chunk); err != nil {
+				panic(err)
+			}
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer ts.Close()
+
+	withTempDir(func(dir string) {
+		src := &testSource{dls: []Download{
+			{URL: ts.URL, Filename: "Dummy.alfredworkflow", Version: mustVersion("0.5"), Size: size},
+		}}
+		u, err := NewUpdater(src, "0.2.2", dir)
+		require.Nil(t, err, "create updater failed")
+		require.Nil(t, u.CheckForUpdate(), "get releases failed")
+
+		var calls []int64
+		u.Progress = func(done, total int64) {
+			assert.Equal(t, int64(size), total, "unexpected total")
+			calls = append(calls, done)
+		}
+		require.Nil(t, u.Install(), "install failed")
+
+		require.True(t, len(calls) > 1, "progress not reported")
+		for i := 1; i < len(calls); i++ {
+			assert.True(t, calls[i] > calls[i-1], "progress did not increase")
+		}
+		assert.Equal(t, int64(size), calls[len(calls)-1], "unexpected final progress")
+	})
+}
+
+// Cancelling InstallContext deletes the partial download.
+func TestUpdater_InstallContext_cancel(t *testing.T) {
+	origRun := runCommand
+	defer func() { runCommand = origRun }()
+	me := &mockExec{}
+	runCommand = me.Run
+
+	const size = 64 * 1024
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+		if _, err := w.Write(make([]byte, 1024)); err != nil {
+			panic(err)
+		}
+		w.(http.Flusher).Flush()
+		// stall until client gives up
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	withTempDir(func(dir string) {
+		src := &testSource{dls: []Download{
+			{URL: ts.URL, Filename: "Dummy.alfredworkflow", Version: mustVersion("0.5"), Size: size},
+		}}
+		u, err := NewUpdater(src, "0.2.2", dir)
+		require.Nil(t, err, "create updater failed")
+		require.Nil(t, u.CheckForUpdate(), "get releases failed")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		// cancel once download has started
+		u.Progress = func(done, total int64) { cancel() }
+
+		err = u.InstallContext(ctx)
+		require.NotNil(t, err, "cancelled install succeeded")
+		assert.True(t, errors.Is(err, context.Canceled), "unexpected error: %v", err)
+		assert.False(t, util.PathExists(filepath.Join(dir, "Dummy.alfredworkflow")), "partial download not deleted")
+		assert.Equal(t, "", me.name, "partial download opened")
+	})
+}
+
 func TestHTTPClient(t *testing.T) {
 	t.Parallel()
 
@@ -251,7 +631,7 @@ func TestHTTPClient(t *testing.T) {
 		require.Nil(t, err, "create tempfile failed")
 		defer panicOnError(f.Close())
 
-		err = download(ts.URL, f.Name())
+		err = download(context.Background(), ts.URL, f.Name(), nil)
 		require.Nil(t, err, "download failed")
 
 		data, err := ioutil.ReadFile(f.Name())
@@ -270,7 +650,7 @@ func TestHTTPClient(t *testing.T) {
 		URL := ts.URL
 		ts.Close()
 
-		err := download(URL, "")
+		err := download(context.Background(), URL, "", nil)
 		require.NotNil(t, err, "bad download succeeded")
 	})
 }
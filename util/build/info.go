@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -40,7 +41,8 @@ func LibDir(dir string) Option {
 	}
 }
 
-// InfoPlist tells New to parse a specific info.plist file. Default is ./info.plist.
+// InfoPlist tells New to parse a specific info.plist file. By default, New
+// looks for info.plist in the working directory and its parents.
 func InfoPlist(path string) Option {
 	return func(info *Info) {
 		info.ipPath = path
@@ -57,6 +59,13 @@ type Info struct {
 	Name     string // Workflow name
 	Version  string // Workflow version
 	BundleID string // Workflow bundle ID
+	// Workflow variables and their default values from info.plist
+	Variables map[string]string
+	// Names of variables marked "Don't Export" in info.plist
+	Unexported []string
+	// Workflow description and readme from info.plist
+	Description string
+	Readme      string
 
 	// Workflow directories
 	CacheDir string // Workflow cache directory
@@ -78,25 +87,31 @@ type Info struct {
 	// Default is ~/Library.
 	dir string
 	// Path to workflow's info.plist.
-	// Default is ./info.plist
+	// Default is the first info.plist found in the working
+	// directory or its parents.
 	ipPath string
 }
 
 // NewInfo creates a new Info. Workflow info is read from Alfred environment
-// variables (if set), and from info.plist in the working directory and
-// Alfred's configuration files. These paths may be changed using the
-// the LibDir and InfoPlist Options. Settings from info.plist take priority
-// over those from environment variables.
+// variables (if set), and from info.plist in the working directory (or the
+// nearest parent directory containing one) and Alfred's configuration
+// files. These paths may be changed using the LibDir and InfoPlist Options.
+// Settings from info.plist take priority over those from environment
+// variables.
 //
 // It returns an error if info.plist or the configuration files cannot be found.
 func NewInfo(option ...Option) (*Info, error) {
-	info := &Info{
-		dir:    os.ExpandEnv("${HOME}/Library"),
-		ipPath: "info.plist",
-	}
+	info := &Info{dir: os.ExpandEnv("${HOME}/Library")}
 	for _, opt := range option {
 		opt(info)
 	}
+	if info.ipPath == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		info.ipPath = findInfoPlist(wd)
+	}
 	info.readEnv()
 	if err := info.readPlist(); err != nil {
 		return nil, err
@@ -136,20 +151,10 @@ func (info *Info) findFolders() error {
 	info.AlfredWorkflowDir = filepath.Join(syncDir, "Alfred.alfredpreferences/workflows")
 	info.InstallDir = filepath.Join(info.AlfredWorkflowDir, info.BundleID)
 	if info.AlfredCacheDir == "" {
-		switch info.AlfredMajorVersion {
-		case 3:
-			info.AlfredCacheDir = os.ExpandEnv("${HOME}/Library/Caches/com.runningwithcrayons.Alfred-3/Workflow Data")
-		default:
-			info.AlfredCacheDir = os.ExpandEnv("${HOME}/Library/Caches/com.runningwithcrayons.Alfred/Workflow Data")
-		}
+		info.AlfredCacheDir = AlfredCacheDir(info.AlfredMajorVersion)
 	}
 	if info.AlfredDataDir == "" {
-		switch info.AlfredMajorVersion {
-		case 3:
-			info.AlfredDataDir = os.ExpandEnv("${HOME}/Library/Application Support/Alfred 3/Workflow Data")
-		default:
-			info.AlfredDataDir = os.ExpandEnv("${HOME}/Library/Application Support/Alfred/Workflow Data")
-		}
+		info.AlfredDataDir = AlfredDataDir(info.AlfredMajorVersion)
 	}
 	if info.CacheDir == "" {
 		info.CacheDir = filepath.Join(info.AlfredCacheDir, info.BundleID)
@@ -210,13 +215,24 @@ func (info *Info) readPlist() error {
 	}
 
 	p := struct {
-		Name     string `plist:"name"`
-		Version  string `plist:"version"`
-		BundleID string `plist:"bundleid"`
+		Name        string            `plist:"name"`
+		Version     string            `plist:"version"`
+		BundleID    string            `plist:"bundleid"`
+		Description string            `plist:"description"`
+		Readme      string            `plist:"readme"`
+		Variables   map[string]string `plist:"variables"`
+		Unexported  []string          `plist:"variablesdontexport"`
 	}{}
 	if _, err = plist.Unmarshal(data, &p); err != nil {
 		return err
 	}
+	info.Description = p.Description
+	info.Readme = p.Readme
+	info.Variables = p.Variables
+	info.Unexported = p.Unexported
+	if info.Variables == nil {
+		info.Variables = map[string]string{}
+	}
 	if p.Name != "" {
 		info.Name = p.Name
 	}
@@ -229,6 +245,48 @@ func (info *Info) readPlist() error {
 	return nil
 }
 
+// Variables returns the workflow variables and their default values
+// defined in the info.plist file at path.
+func Variables(path string) (map[string]string, error) {
+	info, err := ReadInfoPlist(path)
+	if err != nil {
+		return nil, err
+	}
+	return info.Variables, nil
+}
+
+// ReadInfoPlist returns an Info populated only with the values read from
+// the info.plist file at path: Name, Version, BundleID, Variables,
+// Unexported, Description and Readme. Unlike NewInfo, it doesn't read
+// environment variables or Alfred's configuration.
+func ReadInfoPlist(path string) (*Info, error) {
+	info := &Info{ipPath: path}
+	if err := info.readPlist(); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// findInfoPlist returns the path of the first info.plist in dir or its
+// parents. If there is none, it returns the path of (non-existent)
+// info.plist in dir.
+func findInfoPlist(dir string) string {
+	start := filepath.Clean(dir)
+	dir = start
+	for {
+		p := filepath.Join(dir, "info.plist")
+		if util.PathExists(p) {
+			return p
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	return filepath.Join(start, "info.plist")
+}
+
 // expand ~ in a filepath.
 func expand(path string) string {
 	if path == "~" || strings.HasPrefix(path, "~/") {
@@ -237,6 +295,24 @@ func expand(path string) string {
 	return path
 }
 
+// AlfredCacheDir returns the root directory for workflow cache data used
+// by the specified major version of Alfred.
+func AlfredCacheDir(majorVersion int) string {
+	if majorVersion == 3 {
+		return os.ExpandEnv("${HOME}/Library/Caches/com.runningwithcrayons.Alfred-3/Workflow Data")
+	}
+	return os.ExpandEnv("${HOME}/Library/Caches/com.runningwithcrayons.Alfred/Workflow Data")
+}
+
+// AlfredDataDir returns the root directory for persistent workflow data
+// used by the specified major version of Alfred.
+func AlfredDataDir(majorVersion int) string {
+	if majorVersion == 3 {
+		return os.ExpandEnv("${HOME}/Library/Application Support/Alfred 3/Workflow Data")
+	}
+	return os.ExpandEnv("${HOME}/Library/Application Support/Alfred/Workflow Data")
+}
+
 // get path to Alfred's sync folder (parent of Alfred.alfredpreferences) from
 // environment or Alfred's config files
 func findSyncFolder(v int, dir string) (string, error) {
@@ -267,18 +343,24 @@ func findSyncFolder(v int, dir string) (string, error) {
 		if err = json.Unmarshal(data, &prefs); err != nil {
 			return "", err
 		}
+		var p string
 		if v == 0 {
-			return filepath.Dir(prefs.Current), nil
+			p = filepath.Dir(prefs.Current)
+		} else {
+			path, ok := prefs.Versions[fmt.Sprintf("%d", v)]
+			if !ok {
+				return "", fmt.Errorf("no syncfolder for version %d", v)
+			}
+			p = expand(path)
 		}
 
-		if path, ok := prefs.Versions[fmt.Sprintf("%d", v)]; ok {
-			p := expand(path)
-			if util.PathExists(p) {
-				return p, nil
-			}
-			return defaultSyncDirV4, nil
+		// configured syncfolder may not exist, e.g. a Dropbox folder
+		// on a machine that hasn't synced yet
+		if util.PathExists(p) {
+			return p, nil
 		}
-		return "", fmt.Errorf("no syncfolder for version %d", v)
+		log.Printf("[warning] syncfolder %q does not exist, using default: %s", p, defaultSyncDirV4)
+		return defaultSyncDirV4, nil
 	}
 
 	// Look for Alfred 3 preferences plist
@@ -301,6 +383,7 @@ func findSyncFolder(v int, dir string) (string, error) {
 		if util.PathExists(p) {
 			return p, nil
 		}
+		log.Printf("[warning] syncfolder %q does not exist, using default: %s", p, defaultSyncDirV3)
 		return defaultSyncDirV3, nil
 	}
 
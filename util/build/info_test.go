@@ -6,6 +6,7 @@ package build
 import (
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -16,6 +17,7 @@ var (
 	rootDirV3      = "./testdata/v3"
 	rootDirV4      = "./testdata/v4"
 	rootDirInvalid = "./testdata/invalid"
+	rootDirMissing = "./testdata/v4-missing"
 	syncDirV3      = os.ExpandEnv("${HOME}/Library/Application Support/Alfred 3")
 	prefsBundleV3  = os.ExpandEnv("${HOME}/Library/Application Support/Alfred 3/Alfred.alfredpreferences")
 	wfDirV3        = os.ExpandEnv("${HOME}/Library/Application Support/Alfred 3/Alfred.alfredpreferences/workflows")
@@ -90,6 +92,46 @@ func TestWorkflowInfo(t *testing.T) {
 	})
 }
 
+// Workflow variables are read from info.plist.
+func TestVariables(t *testing.T) {
+	t.Parallel()
+
+	x := map[string]string{
+		"exported_var":   "exported_value",
+		"unexported_var": "unexported_value",
+	}
+	info, err := NewInfo(LibDir(rootDirV4), testPlist)
+	require.Nil(t, err, "NewInfo failed")
+	assert.Equal(t, x, info.Variables, "unexpected variables")
+	assert.Equal(t, []string{"unexported_var"}, info.Unexported, "unexpected unexported variables")
+
+	vars, err := Variables("./testdata/info.plist")
+	require.Nil(t, err, "Variables failed")
+	assert.Equal(t, x, vars, "unexpected variables")
+
+	_, err = Variables("./testdata/does-not-exist.plist")
+	assert.NotNil(t, err, "read non-existent info.plist")
+}
+
+// Description and readme are read from info.plist.
+func TestReadInfoPlist(t *testing.T) {
+	t.Parallel()
+
+	info, err := NewInfo(LibDir(rootDirV4), testPlist)
+	require.Nil(t, err, "NewInfo failed")
+	assert.Equal(t, "AwGo sample info.plist", info.Description, "unexpected description")
+	assert.Equal(t, "", info.Readme, "unexpected readme")
+
+	info, err = ReadInfoPlist("./testdata/info.plist")
+	require.Nil(t, err, "ReadInfoPlist failed")
+	assert.Equal(t, "AwGo", info.Name, "unexpected name")
+	assert.Equal(t, "net.deanishe.awgo", info.BundleID, "unexpected bundle ID")
+	assert.Equal(t, "AwGo sample info.plist", info.Description, "unexpected description")
+
+	_, err = ReadInfoPlist("./testdata/does-not-exist.plist")
+	assert.NotNil(t, err, "read non-existent info.plist")
+}
+
 // Read Alfred version number from environment or based on
 // presence of configuration files.
 func TestAlfredVersion(t *testing.T) {
@@ -187,6 +229,77 @@ func TestDirs(t *testing.T) {
 	}
 }
 
+// Cache and data directories follow each Alfred version's layout.
+func TestAlfredDirs(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, cacheDirV3, AlfredCacheDir(3), "unexpected Alfred 3 cache dir")
+	assert.Equal(t, dataDirV3, AlfredDataDir(3), "unexpected Alfred 3 data dir")
+	assert.Equal(t, cacheDirV4, AlfredCacheDir(4), "unexpected Alfred 4 cache dir")
+	assert.Equal(t, dataDirV4, AlfredDataDir(4), "unexpected Alfred 4 data dir")
+}
+
+// Fall back to default syncfolder if the configured one doesn't exist.
+func TestFindSyncFolder_missing(t *testing.T) {
+	tests := []struct {
+		name    string
+		version int
+	}{
+		{"current", 0},
+		{"v4", 4},
+	}
+
+	for _, td := range tests {
+		td := td // pin variable
+		t.Run(td.name, func(t *testing.T) {
+			withEnv(map[string]string{"alfred_preferences": ""}, func() {
+				dir, err := findSyncFolder(td.version, rootDirMissing)
+				require.Nil(t, err, "findSyncFolder failed")
+				assert.Equal(t, syncDirV4, dir, "unexpected syncfolder")
+			})
+		})
+	}
+}
+
+// info.plist is found in a parent directory.
+func TestFindInfoPlist(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		dir, x string
+	}{
+		{"./testdata/workflow", "testdata/workflow/info.plist"},
+		{"./testdata", "testdata/info.plist"},
+		{"./testdata/v4", "testdata/info.plist"},
+		{"./testdata/v4/Application Support/Alfred", "testdata/info.plist"},
+	}
+
+	for _, td := range tests {
+		td := td // pin variable
+		t.Run(td.dir, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, td.x, findInfoPlist(td.dir), "unexpected info.plist")
+		})
+	}
+}
+
+// NewInfo finds info.plist when run from a subdirectory.
+func TestNewInfo_nested(t *testing.T) {
+	wd, err := os.Getwd()
+	require.Nil(t, err, "Getwd failed")
+	libDir := filepath.Join(wd, rootDirV4)
+
+	require.Nil(t, os.Chdir("./testdata/v4/Application Support"), "Chdir failed")
+	defer func() { panicOnError(os.Chdir(wd)) }()
+
+	withEnv(map[string]string{"alfred_workflow_name": "", "alfred_workflow_bundleid": ""}, func() {
+		info, err := NewInfo(LibDir(libDir))
+		require.Nil(t, err, "NewInfo failed")
+		assert.Equal(t, "AwGo", info.Name, "unexpected name")
+		assert.Equal(t, "net.deanishe.awgo", info.BundleID, "unexpected bundle ID")
+	})
+}
+
 func TestEnv(t *testing.T) {
 	t.Parallel()
 
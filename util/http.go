@@ -0,0 +1,106 @@
+// Copyright (c) 2020 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence applies http://opensource.org/licenses/MIT
+
+package util
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// HTTPTimeout is the maximum time Get and Download wait for a request
+// to complete, including connecting and reading the response body.
+var HTTPTimeout = 60 * time.Second
+
+// Transport shared by Get and Download, so connections are re-used.
+var transport = &http.Transport{
+	Proxy: http.ProxyFromEnvironment,
+	Dial: (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}).Dial,
+	TLSHandshakeTimeout:   30 * time.Second,
+	ResponseHeaderTimeout: 30 * time.Second,
+	ExpectContinueTimeout: 10 * time.Second,
+}
+
+// httpClient returns an http.Client that uses the current HTTPTimeout.
+func httpClient() *http.Client {
+	return &http.Client{Transport: transport, Timeout: HTTPTimeout}
+}
+
+// openURL returns the response for a GET request to URL. It returns an
+// error if the HTTP status code > 299.
+func openURL(URL string) (*http.Response, error) {
+	log.Printf("fetching %s ...", URL)
+	r, err := httpClient().Get(URL)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("[%d] %s", r.StatusCode, URL)
+	if r.StatusCode > 299 {
+		r.Body.Close()
+		return nil, errors.New(r.Status)
+	}
+	return r, nil
+}
+
+// Get returns the contents of URL. It returns an error if the connection
+// fails or times out, or if the server responds with an HTTP status
+// code > 299.
+func Get(URL string) ([]byte, error) {
+	r, err := openURL(URL)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+	return ioutil.ReadAll(r.Body)
+}
+
+// Download saves the contents of URL to path. Like WriteFile, the data are
+// written to a temporary file first, so path is only replaced if the
+// download succeeds. Parent directories of path are created if necessary.
+func Download(URL, path string) error {
+	r, err := openURL(URL)
+	if err != nil {
+		return err
+	}
+	defer r.Body.Close()
+
+	dir, name := filepath.Split(path)
+	if dir != "" {
+		MustExist(dir)
+	}
+	f, err := ioutil.TempFile(dir, name)
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer func() {
+		// Ensure tempfile is deleted
+		if err := os.Remove(tmp); err != nil && !os.IsNotExist(err) {
+			log.Printf("[ERROR] tempfile: %v", err)
+		}
+	}()
+
+	// a body shorter than its Content-Length fails with io.ErrUnexpectedEOF
+	n, err := io.Copy(f, r.Body)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	log.Printf("wrote %q (%d bytes)", PrettyPath(path), n)
+	return nil
+}
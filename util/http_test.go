@@ -0,0 +1,86 @@
+// Copyright (c) 2020 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence applies http://opensource.org/licenses/MIT
+
+package util
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// withTestServer starts a server with handlers for the paths /ok (returns
+// "hello"), /missing (404) and /slow (responds after a delay), and lowers
+// HTTPTimeout so requests time out before /slow responds.
+func withTestServer(fn func(baseURL string)) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	})
+	mux.HandleFunc("/missing", http.NotFound)
+	done := make(chan struct{})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(2 * time.Second):
+		case <-done:
+		}
+		_, _ = w.Write([]byte("too late"))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	defer close(done)
+
+	orig := HTTPTimeout
+	HTTPTimeout = 200 * time.Millisecond
+	defer func() { HTTPTimeout = orig }()
+
+	fn(ts.URL)
+}
+
+func TestGet(t *testing.T) {
+	withTestServer(func(baseURL string) {
+		data, err := Get(baseURL + "/ok")
+		require.Nil(t, err, "Get failed")
+		assert.Equal(t, "hello", string(data), "unexpected response")
+
+		_, err = Get(baseURL + "/missing")
+		require.NotNil(t, err, "404 accepted")
+		assert.Contains(t, err.Error(), "404", "unexpected error")
+
+		_, err = Get(baseURL + "/slow")
+		require.NotNil(t, err, "slow response accepted")
+		assert.Contains(t, err.Error(), "Timeout", "unexpected error")
+	})
+}
+
+func TestDownload(t *testing.T) {
+	dir, err := ioutil.TempDir("", "awgo-util-")
+	require.Nil(t, err, "create tempdir")
+	defer os.RemoveAll(dir)
+
+	withTestServer(func(baseURL string) {
+		path := filepath.Join(dir, "sub", "file.txt")
+		require.Nil(t, Download(baseURL+"/ok", path), "Download failed")
+		data, err := ioutil.ReadFile(path)
+		require.Nil(t, err, "read download")
+		assert.Equal(t, "hello", string(data), "unexpected contents")
+
+		// failed downloads leave existing file untouched
+		assert.NotNil(t, Download(baseURL+"/missing", path), "404 accepted")
+		assert.NotNil(t, Download(baseURL+"/slow", path), "slow response accepted")
+		data, err = ioutil.ReadFile(path)
+		require.Nil(t, err, "read download")
+		assert.Equal(t, "hello", string(data), "file overwritten")
+
+		infos, err := ioutil.ReadDir(filepath.Dir(path))
+		require.Nil(t, err, "read dir")
+		assert.Equal(t, 1, len(infos), "tempfile not deleted")
+	})
+}
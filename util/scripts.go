@@ -5,13 +5,18 @@ package util
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 // ErrUnknownFileType is returned by Run for files it can't identify.
@@ -128,13 +133,92 @@ func RunJS(script string, args ...string) (string, error) {
 	return runOsaScript(script, "JavaScript", args...)
 }
 
+// RunASTimeout is like RunAS, but the script is killed if it hasn't
+// finished after d, e.g. because the application it's talking to is busy.
+//
+// If the script times out, the returned error wraps ErrTimeout. If the
+// script fails, the error is a *ScriptError.
+func RunASTimeout(d time.Duration, script string, args ...string) (string, error) {
+	return runOsaScriptTimeout(d, script, "AppleScript", args...)
+}
+
+// RunJSTimeout is like RunASTimeout, but for JavaScript (JXA).
+func RunJSTimeout(d time.Duration, script string, args ...string) (string, error) {
+	return runOsaScriptTimeout(d, script, "JavaScript", args...)
+}
+
+// ErrTimeout is returned by RunASTimeout and RunJSTimeout if a script
+// doesn't finish in time.
+var ErrTimeout = errors.New("script timed out")
+
+// ScriptError is returned by RunASTimeout and RunJSTimeout if a script
+// fails. Message and Code are parsed from osascript's error output,
+// e.g. "execution error: The variable foo is not defined. (-2753)".
+// If the output can't be parsed, Message is the whole output and Code is 0.
+type ScriptError struct {
+	Message string // Error message
+	Code    int    // AppleScript error number
+	Err     error  // Error returned by exec.Cmd
+}
+
+// Error implements error.
+func (e *ScriptError) Error() string {
+	if e.Code != 0 {
+		return fmt.Sprintf("script error %d: %s", e.Code, e.Message)
+	}
+	return fmt.Sprintf("script error: %s", e.Message)
+}
+
+// Unwrap returns the error returned by exec.Cmd.
+func (e *ScriptError) Unwrap() error { return e.Err }
+
+// rxScriptError matches errors in osascript's output.
+var rxScriptError = regexp.MustCompile(`(?:execution|syntax) error: (.*) \((-?\d+)\)\s*$`)
+
+// newScriptError creates a ScriptError from osascript's STDERR output.
+func newScriptError(stderr string, err error) *ScriptError {
+	stderr = strings.TrimSpace(stderr)
+	if m := rxScriptError.FindStringSubmatch(stderr); m != nil {
+		code, _ := strconv.Atoi(m[2])
+		return &ScriptError{Message: m[1], Code: code, Err: err}
+	}
+	if stderr == "" {
+		stderr = err.Error()
+	}
+	return &ScriptError{Message: stderr, Err: err}
+}
+
+// runOsaScriptTimeout executes a script with osascript, killing it if
+// it takes longer than d.
+func runOsaScriptTimeout(d time.Duration, script, lang string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+
+	argv := append([]string{"-l", lang, "-e", script}, args...)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, osascript, argv...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("%w after %v", ErrTimeout, d)
+		}
+		return "", newScriptError(stderr.String(), err)
+	}
+	return strings.TrimSuffix(stdout.String(), "\n"), nil
+}
+
+// path to osascript. Overridden in tests.
+var osascript = "/usr/bin/osascript"
+
 // runOsaScript executes a script with /usr/bin/osascript.
 // It returns the output from STDOUT.
 func runOsaScript(script, lang string, args ...string) (string, error) {
 	argv := []string{"-l", lang, "-e", script}
 	argv = append(argv, args...)
 
-	cmd := exec.Command("/usr/bin/osascript", argv...)
+	cmd := exec.Command(osascript, argv...)
 	data, err := RunCmd(cmd)
 	if err != nil {
 		return "", err
@@ -166,40 +250,49 @@ func RunCmd(cmd *exec.Cmd) ([]byte, error) {
 	return stdout.Bytes(), nil
 }
 
+// RunCmdCombined executes a command and returns its combined STDOUT
+// and STDERR output.
+//
+// As with RunCmd, output is written to the log if the command fails.
+// Unlike RunCmd, the output is also returned along with the error.
+func RunCmdCombined(cmd *exec.Cmd) ([]byte, error) {
+	var out bytes.Buffer
+
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+
+	if err := cmd.Run(); err != nil {
+		log.Printf("------------- %v ---------------", cmd.Args)
+		log.Println(out.String())
+		log.Println("----------------------------------------------")
+		return out.Bytes(), err
+	}
+
+	return out.Bytes(), nil
+}
+
 // QuoteAS converts string to an AppleScript string literal for insertion into AppleScript code.
 // It wraps the value in quotation marks, so don't insert additional ones.
+//
+// Quotation marks in s are inserted with AppleScript's quote constant
+// rather than escaped, e.g. `say "hi"` becomes "say " & quote & "hi" & quote.
+// Backslashes are escaped.
 func QuoteAS(s string) string {
 	if s == "" {
 		return `""`
 	}
 
-	if s == `"` {
-		return "quote"
-	}
-
-	chars := []string{}
-	for i, c := range s {
-		if c == '"' {
-			switch i {
-			case 0:
-				chars = append(chars, `quote & "`)
-			case len(s) - 1:
-				chars = append(chars, `" & quote`)
-			default:
-				chars = append(chars, `" & quote & "`)
-			}
-			continue
-		}
-		if i == 0 {
-			chars = append(chars, `"`)
+	var parts []string
+	for i, p := range strings.Split(s, `"`) {
+		if i > 0 {
+			parts = append(parts, "quote")
 		}
-		chars = append(chars, string(c))
-		if i == len(s)-1 {
-			chars = append(chars, `"`)
+		if p != "" {
+			parts = append(parts, `"`+strings.Replace(p, `\`, `\\`, -1)+`"`)
 		}
 	}
 
-	return strings.Join(chars, "")
+	return strings.Join(parts, " & ")
 }
 
 // QuoteJS converts a value into JavaScript source code.
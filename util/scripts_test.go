@@ -4,13 +4,19 @@
 package util
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestExecutableRunner(t *testing.T) {
@@ -144,6 +150,21 @@ func TestNoRun(t *testing.T) {
 	}
 }
 
+// RunCmdCombined returns STDOUT and STDERR.
+func TestRunCmdCombined(t *testing.T) {
+	t.Parallel()
+
+	script := "echo to-stdout; echo to-stderr >&2"
+	out, err := RunCmdCombined(exec.Command("/bin/sh", "-c", script))
+	assert.Nil(t, err, "command failed")
+	assert.Contains(t, string(out), "to-stdout", "STDOUT missing")
+	assert.Contains(t, string(out), "to-stderr", "STDERR missing")
+
+	out, err = RunCmdCombined(exec.Command("/bin/sh", "-c", script+"; exit 1"))
+	assert.NotNil(t, err, "failing command succeeded")
+	assert.Contains(t, string(out), "to-stderr", "STDERR missing from failed command")
+}
+
 // TestNewScriptRunner verifies that ScriptRunner accepts the correct filetypes.
 func TestNewScriptRunner(t *testing.T) {
 	data := []struct {
@@ -191,6 +212,50 @@ func TestNewScriptRunner(t *testing.T) {
 	}
 }
 
+// TestQuoteAS verifies QuoteAS quoting.
+func TestQuoteAS(t *testing.T) {
+	t.Parallel()
+
+	data := []struct {
+		in, out string
+	}{
+		{"", `""`},
+		{"simple", `"simple"`},
+		{`"`, `quote`},
+		{`""`, `quote & quote`},
+		{`"""`, `quote & quote & quote`},
+		{`a "b" c`, `"a " & quote & "b" & quote & " c"`},
+		{`"quoted"`, `quote & "quoted" & quote`},
+		{`a""b`, `"a" & quote & quote & "b"`},
+		{`back\slash`, `"back\\slash"`},
+		{`\"`, `"\\" & quote`},
+		{"café", `"café"`},
+		{`"café"`, `quote & "café" & quote`},
+		{"ünïcödé \"quotes\"", `"ünïcödé " & quote & "quotes" & quote`},
+	}
+
+	for _, td := range data {
+		td := td
+		t.Run(td.in, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, td.out, QuoteAS(td.in), "unexpected quoted AS")
+			roundTripAS(t, td.in)
+		})
+	}
+}
+
+// roundTripAS checks that AppleScript returns s unchanged after it has
+// been quoted with QuoteAS. It does nothing if osascript isn't available.
+func roundTripAS(t *testing.T, s string) {
+	if runtime.GOOS != "darwin" {
+		return
+	}
+	out, err := RunAS("return " + QuoteAS(s))
+	if assert.Nil(t, err, "run AppleScript") {
+		assert.Equal(t, s, out, "AppleScript round-trip failed")
+	}
+}
+
 // TestQuoteJS verifies QuoteJS quoting.
 func TestQuoteJS(t *testing.T) {
 	data := []struct {
@@ -207,3 +272,71 @@ func TestQuoteJS(t *testing.T) {
 		assert.Equal(t, td.out, QuoteJS(td.in), "unexpected quoted JS")
 	}
 }
+
+// call fn with osascript replaced by a shell script with the given body.
+func withFakeOsascript(body string, fn func()) {
+	dir, err := ioutil.TempDir("", "awgo-")
+	if err != nil {
+		panic(err)
+	}
+	defer os.RemoveAll(dir)
+
+	p := filepath.Join(dir, "osascript")
+	if err := ioutil.WriteFile(p, []byte("#!/bin/sh\n"+body+"\n"), 0700); err != nil {
+		panic(err)
+	}
+	orig := osascript
+	osascript = p
+	defer func() { osascript = orig }()
+	fn()
+}
+
+// Scripts are killed after the timeout.
+func TestRunASTimeout(t *testing.T) {
+	run := func() {
+		start := time.Now()
+		_, err := RunASTimeout(100*time.Millisecond, "delay 5")
+		require.NotNil(t, err, "script didn't time out")
+		assert.True(t, errors.Is(err, ErrTimeout), "not a timeout error: %v", err)
+		assert.True(t, time.Since(start) < 5*time.Second, "script wasn't killed")
+	}
+
+	if runtime.GOOS == "darwin" {
+		run()
+		return
+	}
+	withFakeOsascript("exec sleep 5", run)
+}
+
+// Script errors are parsed from osascript's output.
+func TestRunASTimeout_scriptError(t *testing.T) {
+	tests := []struct {
+		stderr string
+		msg    string
+		code   int
+	}{
+		{"0:3: execution error: The variable foo is not defined. (-2753)",
+			"The variable foo is not defined.", -2753},
+		{"0:5: syntax error: Expected end of line but found identifier. (-2741)",
+			"Expected end of line but found identifier.", -2741},
+		{"something went wrong", "something went wrong", 0},
+	}
+
+	for _, td := range tests {
+		withFakeOsascript(fmt.Sprintf("echo '%s' >&2\nexit 1", td.stderr), func() {
+			_, err := RunASTimeout(time.Second, "")
+			require.NotNil(t, err, "script didn't fail")
+			assert.False(t, errors.Is(err, ErrTimeout), "script error is a timeout")
+			var se *ScriptError
+			require.True(t, errors.As(err, &se), "not a ScriptError: %v", err)
+			assert.Equal(t, td.msg, se.Message, "unexpected message")
+			assert.Equal(t, td.code, se.Code, "unexpected code")
+		})
+	}
+
+	withFakeOsascript(`echo "$2"`, func() {
+		out, err := RunJSTimeout(time.Second, "")
+		require.Nil(t, err, "script failed")
+		assert.Equal(t, "JavaScript", out, "unexpected output")
+	})
+}
@@ -5,8 +5,8 @@
 
 Package util contains general helper functions for workflow (library) authors.
 
-The functions can be divided into roughly three groups: paths, formatting
-and scripting.
+The functions can be divided into roughly four groups: paths, formatting,
+scripting and HTTP.
 
 
 Paths
@@ -36,6 +36,13 @@ appropriate interpreter.
 
 See Runner for more information.
 
+
+HTTP
+
+Get fetches the contents of a URL and Download saves them to a file.
+Both give up after HTTPTimeout and treat HTTP error status
+codes as errors.
+
 */
 package util
 
@@ -4,6 +4,7 @@
 package aw
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
@@ -11,6 +12,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime/debug"
+	"strings"
 	"sync"
 	"time"
 
@@ -18,6 +20,7 @@ import (
 
 	"github.com/deanishe/awgo/keychain"
 	"github.com/deanishe/awgo/util"
+	"github.com/deanishe/awgo/util/build"
 )
 
 // AwGoVersion is the semantic version number of this library.
@@ -43,6 +46,8 @@ var (
 	// Flag, as we only want to set up logging once
 	// TODO: Better, more pluggable logging
 	logInitialized bool
+	// Log file opened by initializeLogging
+	logFile io.Writer
 )
 
 // init creates the default Workflow.
@@ -117,18 +122,28 @@ type Workflow struct {
 
 	logPrefix   string         // Written to debugger to force a newline
 	maxLogSize  int            // Maximum size of log file in bytes
+	logWriters  []io.Writer    // Additional log destinations
+	logReplace  bool           // Don't log to file and STDERR
 	magicPrefix string         // Overrides DefaultMagicPrefix for magic actions.
 	maxResults  int            // max. results to send to Alfred. 0 means send all.
+	warnEmpty   [2]string      // Title & subtitle of warning sent if there are no items
 	sortOptions []fuzzy.Option // Options for fuzzy filtering
 	textErrors  bool           // Show errors as plaintext, not Alfred JSON
+	noRescue    bool           // Don't recover panics in Run()
+	runTimeout  time.Duration  // Max. time Run() waits for fn. 0 means no limit.
+	compact     bool           // Send minified JSON to Alfred
 	helpURL     string         // URL to help page (shown if there's an error)
 	dir         string         // Directory workflow is in
 	cacheDir    string         // Workflow's cache directory
 	dataDir     string         // Workflow's data directory
 	sessionName string         // Name of the variable sessionID is stored in
 	sessionID   string         // Random session ID
+	sent        bool           // Set when output has been written to STDOUT
+	args        []string       // Overrides os.Args[1:] if not nil
+	outputMu    sync.Mutex     // Serialises sending output to Alfred
 
-	execFunc commandRunner // Run external commands
+	execFunc   commandRunner        // Run external commands
+	beforeSend []func(fb *Feedback) // Called by SendFeedback before sending
 }
 
 // New creates and initialises a new Workflow, passing any Options to
@@ -145,21 +160,24 @@ type Workflow struct {
 //     alfred_workflow_cache
 //     alfred_workflow_data
 //
+// If any are missing, or the cache or data directory can't be created,
+// New shows an error in Alfred explaining what is misconfigured and
+// exits the program.
+//
 // If you aren't running from Alfred, or would like to specify a
 // custom environment, use NewFromEnv().
 func New(opts ...Option) *Workflow { return NewFromEnv(nil, opts...) }
 
 // NewFromEnv creates a new Workflows from the specified Env.
 // If env is nil, the system environment is used.
+//
+// The cache and data directory variables are not required if the
+// directories are set with the CacheDirOption and DataDirOption Options.
 func NewFromEnv(env Env, opts ...Option) *Workflow {
 	if env == nil {
 		env = sysEnv{}
 	}
 
-	if err := validateEnv(env); err != nil {
-		panic(err)
-	}
-
 	wf := &Workflow{
 		Config:      NewConfig(env),
 		Alfred:      NewAlfred(env),
@@ -181,6 +199,7 @@ func NewFromEnv(env Env, opts ...Option) *Workflow {
 	wf.Configure(AddMagic(
 		logMA{wf},
 		cacheMA{wf},
+		folderMA{wf},
 		clearCacheMA{wf},
 		dataMA{wf},
 		clearDataMA{wf},
@@ -189,6 +208,13 @@ func NewFromEnv(env Env, opts ...Option) *Workflow {
 
 	wf.Configure(opts...)
 
+	if err := validateEnv(env, wf.dirsSetByOptions()...); err != nil {
+		wf.fatalEnv(err)
+	}
+	if err := checkDirs(false, wf.CacheDir(), wf.DataDir()); err != nil {
+		wf.fatalEnv(err)
+	}
+
 	wf.Cache = NewCache(wf.CacheDir())
 	wf.Data = NewCache(wf.DataDir())
 	wf.Session = NewSession(wf.CacheDir(), wf.SessionID())
@@ -197,6 +223,56 @@ func NewFromEnv(env Env, opts ...Option) *Workflow {
 	return wf
 }
 
+// CheckEnv checks that the variables AwGo requires are set, and that the
+// workflow's cache and data directories exist or can be created, and are
+// writable. It returns an *EnvError explaining any problems. NewFromEnv
+// performs the same checks, except for writability, and shows the user
+// an error in Alfred if they fail, so call CheckEnv for diagnostics, e.g.
+// in a "check setup" action.
+func (wf *Workflow) CheckEnv() error {
+	if err := validateEnv(wf.Config, wf.dirsSetByOptions()...); err != nil {
+		return err
+	}
+	return checkDirs(true, wf.CacheDir(), wf.DataDir())
+}
+
+// dirsSetByOptions returns the names of the directory variables that
+// aren't required because the directories were set via Options.
+func (wf *Workflow) dirsSetByOptions() []string {
+	var vars []string
+	if wf.cacheDir != "" {
+		vars = append(vars, EnvVarCacheDir)
+	}
+	if wf.dataDir != "" {
+		vars = append(vars, EnvVarDataDir)
+	}
+	return vars
+}
+
+// fatalEnv shows the user an error explaining what's wrong with the
+// environment and exits. It panics if exitFunc returns.
+func (wf *Workflow) fatalEnv(err error) {
+	log.Printf("[ERROR] %v", err)
+	msg := err.Error()
+	if e, ok := err.(*EnvError); ok {
+		msg = strings.Join(e.Issues, "; ")
+	}
+	if wf.textErrors {
+		fmt.Print(msg)
+	} else {
+		fb := &Feedback{}
+		fb.NewItem("Workflow is misconfigured").
+			Subtitle(msg).
+			Largetype(msg).
+			Icon(IconError)
+		if err := fb.Send(); err != nil {
+			log.Printf("[ERROR] send error to Alfred: %v", err)
+		}
+	}
+	exitFunc(1)
+	panic(err)
+}
+
 // --------------------------------------------------------------------
 // Initialisation methods
 
@@ -238,8 +314,8 @@ func (wf *Workflow) initializeLogging() {
 	}
 
 	// Attach logger to file
-	multi := io.MultiWriter(file, os.Stderr)
-	log.SetOutput(multi)
+	logFile = file
+	wf.setLogOutput()
 
 	// Show filenames and line numbers if Alfred's debugger is open
 	if wf.Debug() {
@@ -251,6 +327,20 @@ func (wf *Workflow) initializeLogging() {
 	logInitialized = true
 }
 
+// setLogOutput sends log output to the log file and STDERR, plus or
+// instead of any writers set via the LogWriter options.
+func (wf *Workflow) setLogOutput() {
+	var writers []io.Writer
+	if !wf.logReplace {
+		if logFile != nil {
+			writers = append(writers, logFile)
+		}
+		writers = append(writers, os.Stderr)
+	}
+	writers = append(writers, wf.logWriters...)
+	log.SetOutput(io.MultiWriter(writers...))
+}
+
 // --------------------------------------------------------------------
 // API methods
 
@@ -273,6 +363,20 @@ func (wf *Workflow) Name() string { return wf.Config.Get(EnvVarName) }
 // sheet in Alfred Preferences.
 func (wf *Workflow) Version() string { return wf.Config.Get(EnvVarVersion) }
 
+// Description returns the description of the workflow from the info.plist
+// in its directory, as set in the workflow's configuration sheet in Alfred
+// Preferences. Alfred doesn't pass the description to workflows, so it is
+// read from disk. If info.plist can't be read, a warning is logged and
+// an empty string is returned.
+func (wf *Workflow) Description() string {
+	info, err := build.ReadInfoPlist(filepath.Join(wf.Dir(), "info.plist"))
+	if err != nil {
+		log.Printf("[warning] read description from info.plist: %v", err)
+		return ""
+	}
+	return info.Description
+}
+
 // SessionID returns the session ID for this run of the workflow.
 // This is used internally for session-scoped caching.
 //
@@ -297,15 +401,82 @@ func (wf *Workflow) SessionID() string {
 // Debug returns true if Alfred's debugger is open.
 func (wf *Workflow) Debug() bool { return wf.Config.GetBool(EnvVarDebug) }
 
+// RunningInAlfred returns true if the workflow was run by Alfred, i.e.
+// Alfred's workflow and version variables are set. Use it to produce
+// human-readable output when running a workflow from a shell.
+func (wf *Workflow) RunningInAlfred() bool {
+	for _, k := range []string{EnvVarBundleID, EnvVarAlfredVersion} {
+		if wf.Config.Get(k) == "" {
+			return false
+		}
+	}
+	return true
+}
+
 // Args returns command-line arguments passed to the program.
 // It intercepts "magic args" and runs the corresponding actions, terminating
 // the workflow. See MagicAction for full documentation.
 func (wf *Workflow) Args() []string {
-	prefix := DefaultMagicPrefix
+	args := os.Args[1:]
+	if wf.args != nil {
+		args = wf.args
+	}
+	return wf.magicActions.args(args, wf.magicPrefixOrDefault())
+}
+
+// magicPrefixOrDefault returns the prefix for magic actions. The user's
+// setting (workflow variable aw_magic_prefix) takes precedence over the
+// prefix set with MagicPrefix.
+func (wf *Workflow) magicPrefixOrDefault() string {
+	if s := wf.Config.Get(EnvVarMagicPrefix); s != "" {
+		return s
+	}
 	if wf.magicPrefix != "" {
-		prefix = wf.magicPrefix
+		return wf.magicPrefix
 	}
-	return wf.magicActions.args(os.Args[1:], prefix)
+	return DefaultMagicPrefix
+}
+
+// ArgsParsed returns the same arguments as Args, except that a single
+// argument containing a JSON array of strings is decoded into its elements.
+// Alfred 4.1+ passes multiple args to a script this way if they are
+// inserted via {query}. Any other argument, including a JSON array that
+// isn't all strings, is returned as-is.
+func (wf *Workflow) ArgsParsed() []string {
+	args := wf.Args()
+	if len(args) != 1 || !strings.HasPrefix(strings.TrimSpace(args[0]), "[") {
+		return args
+	}
+	var v []string
+	if err := json.Unmarshal([]byte(args[0]), &v); err != nil {
+		return args
+	}
+	return v
+}
+
+// Trigger returns the name of the External Trigger that ran the workflow,
+// or an empty string if the workflow wasn't run by
+// Alfred.RunTriggerWithInfo.
+func (wf *Workflow) Trigger() string { return wf.Config.Get(EnvVarTrigger) }
+
+// TriggerArg returns the argument passed to the External Trigger that ran
+// the workflow. Alfred also passes it to the workflow as its query.
+func (wf *Workflow) TriggerArg() string { return wf.Config.Get(EnvVarTriggerArg) }
+
+// TriggerVars returns the variables passed to Alfred.RunTriggerWithInfo by
+// the caller of the External Trigger that ran the workflow. It returns an
+// empty map if the workflow wasn't run by an External Trigger.
+func (wf *Workflow) TriggerVars() map[string]string {
+	vars := map[string]string{}
+	for _, k := range strings.Split(wf.Config.Get(EnvVarTriggerVars), ",") {
+		if k == "" {
+			continue
+		}
+		if v, ok := wf.Config.Lookup(k); ok {
+			vars[k] = v
+		}
+	}
+	return vars
 }
 
 // Run runs your workflow function, catching any errors.
@@ -339,9 +510,21 @@ func (wf *Workflow) Run(fn func()) {
 	// Catch any `panic` and display an error in Alfred.
 	// Fatal(msg) will terminate the process (via log.Fatal).
 	defer func() {
+		if wf.noRescue {
+			return
+		}
 		if r := recover(); r != nil {
+			stack := debug.Stack()
+			// panic in fn run by runWithTimeout
+			if p, ok := r.(*fnPanic); ok {
+				r, stack = p.value, p.stack
+			}
+			// exit called by workflow run via RunForTest
+			if _, ok := r.(testExit); ok {
+				panic(r)
+			}
 			log.Println(util.Pad(" FATAL ERROR ", "-", 50))
-			log.Printf("%s : %s", r, debug.Stack())
+			log.Printf("%s : %s", r, stack)
 			log.Println(util.Pad(" END STACK TRACE ", "-", 50))
 
 			// log.Printf("Recovered : %x", r)
@@ -355,24 +538,100 @@ func (wf *Workflow) Run(fn func()) {
 	}()
 
 	// Call the workflow's main function.
-	fn()
+	if wf.runTimeout > 0 {
+		wf.runWithTimeout(fn)
+	} else {
+		fn()
+	}
 
 	wf.Wait()
 	finishLog(false)
 }
 
+// fnPanic is a panic recovered from the goroutine running fn, along with
+// the stack trace of where it happened.
+type fnPanic struct {
+	value interface{}
+	stack []byte
+}
+
+// runWithTimeout calls fn in a goroutine and shows an error in Alfred if
+// it doesn't return within wf.runTimeout. Panics in fn are re-raised in
+// the calling goroutine as *fnPanic, so Run can rescue them and log the
+// original stack trace.
+//
+// fn keeps running after a timeout until the process exits. Sending
+// output is serialised, so Alfred receives either fn's feedback or the
+// timeout error, never a mixture of both.
+func (wf *Workflow) runWithTimeout(fn func()) {
+	done := make(chan *fnPanic, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- &fnPanic{r, debug.Stack()}
+				return
+			}
+			done <- nil
+		}()
+		fn()
+	}()
+
+	select {
+	case p := <-done:
+		if p == nil {
+			return
+		}
+		if wf.noRescue {
+			log.Printf("%s : %s", p.value, p.stack)
+			panic(p.value)
+		}
+		panic(p)
+	case <-time.After(wf.runTimeout):
+		wf.outputTimeoutMsg(fmt.Sprintf("Timed out after %v", wf.runTimeout))
+	}
+}
+
 // --------------------------------------------------------------------
 // Helper methods
 
 // outputErrorMsg prints and logs error, then exits process.
 func (wf *Workflow) outputErrorMsg(msg string) {
+	wf.outputMu.Lock()
 	if wf.textErrors {
-		fmt.Print(msg)
+		if wf.commitOutput("error message") {
+			fmt.Print(msg)
+		}
 	} else {
 		wf.Feedback.Clear()
-		wf.NewItem(msg).Icon(IconError)
-		wf.SendFeedback()
+		wf.NewItem(msg).Icon(IconError).Invalid()
+		wf.sendFeedback(os.Stdout)
 	}
+	wf.outputMu.Unlock()
+	wf.logErrorMsg(msg)
+}
+
+// outputTimeoutMsg is like outputErrorMsg, but sends the error in a new
+// Feedback, as fn may still be modifying the workflow's.
+func (wf *Workflow) outputTimeoutMsg(msg string) {
+	wf.outputMu.Lock()
+	if wf.commitOutput("error message") {
+		if wf.textErrors {
+			fmt.Print(msg)
+		} else {
+			fb := NewFeedback()
+			fb.NewItem(msg).Icon(IconError).Invalid()
+			fb.compact = wf.compact && !wf.Debug()
+			if err := fb.Send(); err != nil {
+				log.Printf("[ERROR] send error to Alfred: %v", err)
+			}
+		}
+	}
+	wf.outputMu.Unlock()
+	wf.logErrorMsg(msg)
+}
+
+// logErrorMsg logs error and help URL, then exits process.
+func (wf *Workflow) logErrorMsg(msg string) {
 	log.Printf("[ERROR] %s", msg)
 	// Show help URL or website URL
 	if wf.helpURL != "" {
@@ -381,6 +640,18 @@ func (wf *Workflow) outputErrorMsg(msg string) {
 	finishLog(true)
 }
 
+// commitOutput marks output as sent to Alfred. It returns false (and logs
+// a warning) if output has already been sent, as Alfred can only handle
+// one response.
+func (wf *Workflow) commitOutput(what string) bool {
+	if wf.sent || wf.Feedback.sent {
+		log.Printf("[warning] output already sent to Alfred, ignoring %s", what)
+		return false
+	}
+	wf.sent = true
+	return true
+}
+
 // awDataDir is the directory for AwGo's own data.
 func (wf *Workflow) awDataDir() string {
 	return util.MustExist(filepath.Join(wf.DataDir(), "_aw"))
@@ -4,9 +4,14 @@
 package aw
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"os"
 	"path/filepath"
+	"sort"
+	"time"
 
 	"go.deanishe.net/fuzzy"
 
@@ -22,6 +27,18 @@ func (wf *Workflow) Rerun(secs float64) *Workflow {
 	return wf
 }
 
+// RerunUntilFresh tells Alfred to re-run the Script Filter after `secs`
+// seconds if the named cache is older than maxAge or doesn't exist, e.g.
+// while a background job is updating it. It returns true if the cache
+// has expired.
+func (wf *Workflow) RerunUntilFresh(cache *Cache, name string, maxAge time.Duration, secs float64) bool {
+	if !cache.Expired(name, maxAge) {
+		return false
+	}
+	wf.Rerun(secs)
+	return true
+}
+
 // Vars returns the workflow variables set on Workflow.Feedback.
 // See Feedback.Vars() for more information.
 func (wf *Workflow) Vars() map[string]string {
@@ -41,6 +58,40 @@ func (wf *Workflow) NewItem(title string) *Item {
 	return wf.Feedback.NewItem(title)
 }
 
+// NewItemSub adds a new Item with the given title and subtitle and
+// returns a pointer to it.
+func (wf *Workflow) NewItemSub(title, subtitle string) *Item {
+	return wf.NewItem(title).Subtitle(subtitle)
+}
+
+// AddItems adds a new Item for each of titles and returns the Items.
+func (wf *Workflow) AddItems(titles ...string) []*Item {
+	items := make([]*Item, len(titles))
+	for i, s := range titles {
+		items[i] = wf.NewItem(s)
+	}
+	return items
+}
+
+// AddMapItems adds an Item for each entry in m, sorted by key, and returns
+// the Items. Each Item's arg is the entry's value, and its title is
+// generated by passing titleFmt to fmt.Sprintf with the key and value,
+// e.g. "%s: %s". Use explicit argument indexes to show only one of them,
+// e.g. "%[2]s" for only the value.
+func (wf *Workflow) AddMapItems(m map[string]string, titleFmt string) []*Item {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	items := make([]*Item, len(keys))
+	for i, k := range keys {
+		items[i] = wf.NewItem(fmt.Sprintf(titleFmt, k, m[k])).Arg(m[k])
+	}
+	return items
+}
+
 // NewFileItem adds and returns a new Item pre-populated from path.
 // Title and Autocomplete are the base name of the file,
 // Subtitle is the path to the file (using "~" for $HOME),
@@ -67,7 +118,38 @@ func (wf *Workflow) NewFileItem(path string) *Item {
 func (wf *Workflow) NewWarningItem(title, subtitle string) *Item {
 	return wf.Feedback.NewItem(title).
 		Subtitle(subtitle).
-		Icon(IconWarning)
+		Icon(IconWarning).
+		Invalid()
+}
+
+// AddAboutItem adds and returns an Item showing the workflow's name and
+// version. If a HelpURL is set, the subtitle shows it, and actioning the
+// Item runs the "help" magic action, which opens the URL in the user's
+// browser. The Item isn't valid, so it can't be passed to the next action.
+func (wf *Workflow) AddAboutItem() *Item {
+	title := wf.Name()
+	if v := wf.Version(); v != "" {
+		title += " " + v
+	}
+	it := wf.NewItem(title).Icon(IconInfo).Valid(false)
+	if wf.helpURL != "" {
+		it.Subtitle(wf.helpURL).ExpandTo(wf.magicPrefixOrDefault() + "help")
+	}
+	return it
+}
+
+// ResetFeedback removes all Items and marks feedback as unsent, so a new
+// set of results can be built and sent with SendFeedback(). Workflow
+// variables and the rerun interval are retained.
+//
+// This is only useful for workflows that run as a persistent process,
+// e.g. in a loop driven by External Triggers. Alfred only reads the
+// first response from a normal Script Filter.
+func (wf *Workflow) ResetFeedback() *Workflow {
+	wf.Feedback.Clear()
+	wf.Feedback.sent = false
+	wf.sent = false
+	return wf
 }
 
 // IsEmpty returns true if Workflow contains no items.
@@ -90,13 +172,17 @@ func (wf *Workflow) Fatalf(format string, args ...interface{}) {
 // Warn displays a warning message in Alfred immediately. Unlike
 // FatalError()/Fatal(), this does not terminate the workflow,
 // but you can't send any more results to Alfred.
+//
+// Any existing Items are discarded, but top-level variables set with
+// Workflow.Var() are retained and sent along with the warning.
 func (wf *Workflow) Warn(title, subtitle string) *Workflow {
-	// Remove any existing items
+	// Remove any existing items, but not variables
 	wf.Feedback.Clear()
 
 	wf.NewItem(title).
 		Subtitle(subtitle).
-		Icon(IconWarning)
+		Icon(IconWarning).
+		Invalid()
 
 	return wf.SendFeedback()
 }
@@ -109,14 +195,35 @@ func (wf *Workflow) WarnEmpty(title, subtitle string) {
 }
 
 // Filter fuzzy-sorts feedback Items against query and deletes Items that don't match.
-func (wf *Workflow) Filter(query string) []*fuzzy.Result {
-	return wf.Feedback.Filter(query, wf.sortOptions...)
+//
+// It uses the options set with SortOptions. Any opts are applied after
+// those, so you can override them for a single call, e.g. pass
+// fuzzy.StripDiacritics(false) to only match accented characters exactly.
+func (wf *Workflow) Filter(query string, opts ...fuzzy.Option) []*fuzzy.Result {
+	return wf.Feedback.Filter(query, wf.fuzzyOptions(opts)...)
+}
+
+// Sort fuzzy-sorts feedback Items against query. Unlike Filter, it
+// doesn't delete Items that don't match: they are moved after the
+// matching Items, and their corresponding Results have Match set to false,
+// so you can, e.g., make them invalid or change their icons.
+//
+// Like Filter, it accepts options that override those set with SortOptions.
+func (wf *Workflow) Sort(query string, opts ...fuzzy.Option) []*fuzzy.Result {
+	return wf.Feedback.Sort(query, wf.fuzzyOptions(opts)...)
+}
+
+// fuzzyOptions returns the options set with SortOptions followed by opts.
+func (wf *Workflow) fuzzyOptions(opts []fuzzy.Option) []fuzzy.Option {
+	return append(append([]fuzzy.Option{}, wf.sortOptions...), opts...)
 }
 
 // SendFeedback sends Script Filter results to Alfred.
 //
 // Results are output as JSON to STDOUT. As you can output results only once,
-// subsequent calls to sending methods are logged and ignored.
+// subsequent calls to sending methods are logged and ignored. This also
+// applies to errors shown by the Fatal methods, so a workflow never writes
+// more than one response to STDOUT.
 //
 // The sending methods are:
 //
@@ -127,17 +234,62 @@ func (wf *Workflow) Filter(query string) []*fuzzy.Result {
 //     Warn()
 //     WarnEmpty()  // only sends if there are no items
 //
-func (wf *Workflow) SendFeedback() *Workflow {
+// If the AutoWarnEmpty option is set, SendFeedback sends its warning
+// instead of an empty list of results.
+//
+// In debug mode, SendFeedback logs the number of items, how long sending
+// them took and the total run time so far in the (grep-able) format:
+//
+//     [timing] feedback items=20 send=1.2ms elapsed=45ms
+//
+func (wf *Workflow) SendFeedback() *Workflow { return wf.SendFeedbackTo(os.Stdout) }
+
+// SendFeedbackTo is like SendFeedback, but writes the JSON to w instead
+// of STDOUT. Use it to test a workflow's output or to copy it elsewhere,
+// e.g. io.MultiWriter(os.Stdout, os.Stderr) to also log the results.
+// If writing to w fails, the error is logged. The failed write still counts
+// as the workflow's output (some JSON may already have been written), so
+// subsequent calls to SendFeedbackTo and the like send nothing.
+func (wf *Workflow) SendFeedbackTo(w io.Writer) *Workflow {
+	wf.outputMu.Lock()
+	defer wf.outputMu.Unlock()
+	return wf.sendFeedback(w)
+}
+
+// sendFeedback implements SendFeedbackTo. Callers must hold outputMu.
+func (wf *Workflow) sendFeedback(w io.Writer) *Workflow {
+	if !wf.commitOutput("feedback") {
+		return wf
+	}
+
 	// Set session ID
 	wf.Var("AW_SESSION_ID", wf.SessionID())
 
+	if wf.warnEmpty[0] != "" && wf.IsEmpty() {
+		wf.NewWarningItem(wf.warnEmpty[0], wf.warnEmpty[1])
+	}
+
 	// Truncate Items if maxResults is set
 	if wf.maxResults > 0 && len(wf.Feedback.Items) > wf.maxResults {
 		wf.Feedback.Items = wf.Feedback.Items[0:wf.maxResults]
 	}
 
-	if err := wf.Feedback.Send(); err != nil {
-		log.Fatalf("Error generating JSON : %v", err)
+	for _, fn := range wf.beforeSend {
+		fn(wf.Feedback)
+	}
+
+	wf.Feedback.compact = wf.compact && !wf.Debug()
+	start := time.Now()
+	if err := wf.Feedback.SendTo(w); err != nil {
+		var we *writeError
+		if !errors.As(err, &we) {
+			log.Fatalf("Error generating JSON : %v", err)
+		}
+		log.Printf("[ERROR] %v", err)
+	}
+	if wf.Debug() {
+		log.Printf("[timing] feedback items=%d send=%v elapsed=%v",
+			len(wf.Feedback.Items), time.Since(start), time.Since(startTime))
 	}
 
 	return wf
@@ -4,13 +4,20 @@
 package aw
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.deanishe.net/fuzzy"
 )
 
 func TestItemHelpers(t *testing.T) {
@@ -57,6 +64,65 @@ func TestNewFileItem(t *testing.T) {
 	assert.Equal(t, ipPath, it.icon.Value, "unexpected icon value")
 }
 
+// NewItemSub sets title and subtitle.
+func TestNewItemSub(t *testing.T) {
+	t.Parallel()
+
+	withTestWf(func(wf *Workflow) {
+		it := wf.NewItemSub("title", "subtitle")
+		assert.Equal(t, "title", it.title, "unexpected title")
+		require.NotNil(t, it.subtitle, "subtitle not set")
+		assert.Equal(t, "subtitle", *it.subtitle, "unexpected subtitle")
+		assert.Equal(t, []*Item{it}, wf.Feedback.Items, "item not in feedback")
+	})
+}
+
+// AddItems creates one Item per title.
+func TestAddItems(t *testing.T) {
+	t.Parallel()
+
+	withTestWf(func(wf *Workflow) {
+		titles := []string{"one", "two", "three"}
+		items := wf.AddItems(titles...)
+		require.Equal(t, len(titles), len(items), "unexpected item count")
+		assert.Equal(t, len(titles), len(wf.Feedback.Items), "unexpected feedback count")
+		for i, it := range items {
+			assert.Equal(t, titles[i], it.title, "unexpected title")
+			assert.Equal(t, it, wf.Feedback.Items[i], "item not in feedback")
+		}
+	})
+}
+
+func TestAddMapItems(t *testing.T) {
+	t.Parallel()
+
+	m := map[string]string{
+		"username": "dave",
+		"hostname": "example.com",
+		"port":     "22",
+	}
+
+	withTestWf(func(wf *Workflow) {
+		items := wf.AddMapItems(m, "%s: %s")
+		assert.Equal(t, items, wf.Feedback.Items, "items not in feedback")
+		x := []ItemSnapshot{
+			{Title: "hostname: example.com", Arg: []string{"example.com"}},
+			{Title: "port: 22", Arg: []string{"22"}},
+			{Title: "username: dave", Arg: []string{"dave"}},
+		}
+		assert.Equal(t, x, wf.Feedback.Snapshot(), "unexpected items")
+	})
+
+	withTestWf(func(wf *Workflow) {
+		wf.AddMapItems(m, "%[2]s")
+		var titles []string
+		for _, it := range wf.Feedback.Items {
+			titles = append(titles, it.title)
+		}
+		assert.Equal(t, []string{"example.com", "22", "dave"}, titles, "unexpected titles")
+	})
+}
+
 // TestWarnEmpty verifies Item creation by Workflow.WarnEmpty().
 func TestWarnEmpty(t *testing.T) {
 	wf := New()
@@ -64,3 +130,345 @@ func TestWarnEmpty(t *testing.T) {
 	wf.WarnEmpty("test", "test")
 	assert.Equal(t, 1, len(wf.Feedback.Items), "feedback empty")
 }
+
+// Warn retains top-level variables.
+func TestWarn_vars(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		wf.Var("key", "value")
+		wf.NewItem("item")
+		out := captureStdout(func() { wf.Warn("warning", "") })
+
+		v := struct {
+			Vars  map[string]string `json:"variables"`
+			Items []struct {
+				Title string `json:"title"`
+			} `json:"items"`
+		}{}
+		require.Nil(t, json.Unmarshal([]byte(out), &v), "unmarshal feedback")
+		assert.Equal(t, "value", v.Vars["key"], "variable dropped by Warn")
+		require.Equal(t, 1, len(v.Items), "unexpected item count")
+		assert.Equal(t, "warning", v.Items[0].Title, "unexpected item")
+	})
+}
+
+// Only the first response is sent to Alfred.
+func TestSendFeedback_once(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		out := captureStdout(func() { wf.Warn("warning", "") })
+		assert.Contains(t, out, `"warning"`, "warning not sent")
+
+		wf.NewItem("item")
+		out = captureStdout(func() { wf.SendFeedback() })
+		assert.Equal(t, "", out, "feedback sent after Warn")
+	})
+}
+
+// SendFeedbackTo writes to the given writer, not STDOUT.
+func TestSendFeedbackTo(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		wf.NewItem("item")
+		buf := &bytes.Buffer{}
+		out := captureStdout(func() { wf.SendFeedbackTo(buf) })
+		assert.Equal(t, "", out, "feedback sent to STDOUT")
+		assert.Contains(t, buf.String(), `"item"`, "feedback not sent")
+		assert.True(t, wf.Feedback.sent, "sent not set")
+
+		out = captureStdout(func() { wf.SendFeedback() })
+		assert.Equal(t, "", out, "feedback sent twice")
+	})
+}
+
+type failWriter struct{}
+
+func (failWriter) Write(p []byte) (int, error) { return 0, errors.New("broken pipe") }
+
+// Errors writing feedback are logged, not fatal.
+func TestSendFeedbackTo_writeError(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		var buf bytes.Buffer
+		orig := log.Writer()
+		log.SetOutput(&buf)
+		defer log.SetOutput(orig)
+
+		wf.NewItem("item")
+		wf.SendFeedbackTo(failWriter{})
+		assert.Contains(t, buf.String(), "write feedback: broken pipe", "write error not logged")
+		assert.False(t, wf.Feedback.sent, "sent set after failed write")
+
+		// failed write is final
+		var out bytes.Buffer
+		wf.SendFeedbackTo(&out)
+		assert.Equal(t, "", out.String(), "feedback re-sent after failed write")
+		assert.Contains(t, buf.String(), "output already sent", "retry not refused")
+	})
+}
+
+// CompactOutput sends minified JSON unless debugging.
+func TestSendFeedback_compact(t *testing.T) {
+	send := func(debug bool) string {
+		var out string
+		withTestEnv(func(e MapEnv) {
+			e[EnvVarDebug] = fmt.Sprintf("%v", debug)
+			wf := NewFromEnv(e, CompactOutput(true))
+			wf.NewItem("one").Subtitle("first").Arg("1")
+			wf.NewItem("two").Var("key", "value")
+			out = captureStdout(func() { wf.SendFeedback() })
+		})
+		return out
+	}
+
+	compact, indented := send(false), send(true)
+	assert.NotContains(t, compact, "\n", "compact output contains newlines")
+	assert.Contains(t, indented, "\n", "debug output not indented")
+
+	var a, b interface{}
+	require.Nil(t, json.Unmarshal([]byte(compact), &a), "unmarshal compact output")
+	require.Nil(t, json.Unmarshal([]byte(indented), &b), "unmarshal indented output")
+	assert.Equal(t, b, a, "compact and indented output differ")
+}
+
+// Sort keeps non-matching items after matches.
+func TestWorkflow_Sort(t *testing.T) {
+	t.Parallel()
+
+	withTestWf(func(wf *Workflow) {
+		wf.AddItems("banana", "apple", "cherry", "apricot")
+		res := wf.Sort("ap")
+		require.Equal(t, 4, len(res), "unexpected result count")
+		require.Equal(t, 4, len(wf.Feedback.Items), "items deleted")
+
+		var titles []string
+		for i, it := range wf.Feedback.Items {
+			titles = append(titles, it.title)
+			assert.Equal(t, it.title, res[i].SortKey, "result doesn't match item")
+			assert.Equal(t, i < 2, res[i].Match, "unexpected match for %q", it.title)
+		}
+		assert.ElementsMatch(t, []string{"apple", "apricot"}, titles[:2], "unexpected matches")
+		assert.ElementsMatch(t, []string{"banana", "cherry"}, titles[2:], "unexpected non-matches")
+	})
+}
+
+// Options passed to Filter override the workflow's sort options.
+func TestWorkflow_Filter_options(t *testing.T) {
+	t.Parallel()
+
+	withTestWf(func(wf *Workflow) {
+		wf.Configure(SortOptions(fuzzy.StripDiacritics(true)))
+		wf.AddItems("Résumé", "Resume")
+		res := wf.Filter("resume")
+		assert.Equal(t, 2, len(res), "diacritics not stripped")
+
+		wf.Feedback.Clear()
+		wf.AddItems("Résumé", "Resume")
+		res = wf.Filter("resume", fuzzy.StripDiacritics(false))
+		require.Equal(t, 1, len(res), "diacritics stripped")
+		assert.Equal(t, "Resume", res[0].SortKey, "unexpected match")
+		assert.Equal(t, 1, len(wf.sortOptions), "workflow options changed")
+	})
+}
+
+// Sending time is logged in debug mode.
+func TestSendFeedback_timing(t *testing.T) {
+	rx := regexp.MustCompile(`\[timing\] feedback items=(\d+) send=\S+ elapsed=\S+`)
+	send := func(debug bool) string {
+		var buf bytes.Buffer
+		withTestEnv(func(e MapEnv) {
+			e[EnvVarDebug] = fmt.Sprintf("%v", debug)
+			wf := NewFromEnv(e)
+			wf.NewItem("one")
+			wf.NewItem("two")
+
+			orig := log.Writer()
+			log.SetOutput(&buf)
+			defer log.SetOutput(orig)
+			captureStdout(func() { wf.SendFeedback() })
+		})
+		return buf.String()
+	}
+
+	m := rx.FindStringSubmatch(send(true))
+	require.NotNil(t, m, "timing not logged")
+	assert.Equal(t, "2", m[1], "unexpected item count")
+	assert.False(t, rx.MatchString(send(false)), "timing logged when not debugging")
+}
+
+// DefaultValid makes new Items valid, except warnings.
+func TestDefaultValid(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		before := wf.NewItem("before")
+		prev := wf.Configure(DefaultValid(true))
+		it := wf.NewItem("valid")
+		m := it.NewModifier(ModCmd)
+		inv := wf.NewItem("invalid").Invalid()
+		warn := wf.NewWarningItem("warning", "")
+
+		assert.False(t, before.valid, "item created before option is valid")
+		assert.True(t, it.valid, "item not valid")
+		assert.False(t, inv.valid, "Invalid item is valid")
+		assert.False(t, warn.valid, "warning is valid")
+
+		data, err := json.Marshal(m)
+		require.Nil(t, err, "marshal modifier")
+		assert.Equal(t, `{"valid":true}`, string(data), "modifier didn't inherit valid")
+
+		wf.Configure(prev)
+		assert.False(t, wf.NewItem("reset").valid, "option not reverted")
+	})
+}
+
+// MaxResults truncates sorted results before sending.
+func TestSendFeedback_maxResults(t *testing.T) {
+	withTestEnv(func(e MapEnv) {
+		var buf bytes.Buffer
+		wf := NewFromEnv(e, MaxResults(2))
+		wf.AddItems("banana", "apple", "cherry", "apricot", "grape")
+		wf.Filter("ap")
+		require.Equal(t, 3, len(wf.Feedback.Items), "unexpected match count")
+
+		orig := log.Writer()
+		log.SetOutput(&buf)
+		defer log.SetOutput(orig)
+		out := captureStdout(func() { wf.SendFeedback() })
+
+		var fb struct {
+			Items []struct {
+				Title string `json:"title"`
+			} `json:"items"`
+		}
+		require.Nil(t, json.Unmarshal([]byte(out), &fb), "unmarshal feedback")
+		require.Equal(t, 2, len(fb.Items), "unexpected item count")
+		assert.Equal(t, "apple", fb.Items[0].Title, "unexpected first item")
+		assert.Equal(t, "apricot", fb.Items[1].Title, "unexpected second item")
+		assert.Contains(t, buf.String(), "Sent 2 result(s)", "unexpected log")
+	})
+}
+
+// BeforeSend functions can modify feedback.
+func TestBeforeSend(t *testing.T) {
+	withTestEnv(func(e MapEnv) {
+		var calls []string
+		wf := NewFromEnv(e,
+			BeforeSend(func(fb *Feedback) {
+				calls = append(calls, "one")
+				fb.Var("source", "awgo")
+			}),
+			BeforeSend(func(fb *Feedback) { calls = append(calls, "two") }))
+		wf.NewItem("item")
+
+		out := captureStdout(func() { wf.SendFeedback() })
+		assert.Equal(t, []string{"one", "two"}, calls, "unexpected calls")
+
+		var fb struct {
+			Vars map[string]string `json:"variables"`
+		}
+		require.Nil(t, json.Unmarshal([]byte(out), &fb), "unmarshal feedback")
+		assert.Equal(t, "awgo", fb.Vars["source"], "variable not set")
+	})
+}
+
+// About item shows name & version and opens help.
+func TestWorkflow_AddAboutItem(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		it := wf.AddAboutItem()
+		assert.Equal(t, tName+" "+tVersion, it.title, "unexpected title")
+		assert.Nil(t, it.autocomplete, "autocomplete set without help URL")
+		assert.False(t, it.valid, "about item is valid")
+
+		helpURL := "https://github.com/deanishe/awgo"
+		wf.Configure(HelpURL(helpURL))
+		it = wf.AddAboutItem()
+		assert.Contains(t, it.title, tVersion, "version not in title")
+		require.NotNil(t, it.subtitle, "subtitle not set")
+		assert.Equal(t, helpURL, *it.subtitle, "unexpected subtitle")
+		require.NotNil(t, it.autocomplete, "autocomplete not set")
+		assert.Equal(t, "workflow:help", *it.autocomplete, "unexpected autocomplete")
+
+		// actioning item runs help magic action
+		me := &mockExec{}
+		wf.execFunc = me.Run
+		mx := &mockExit{code: -1}
+		exitFunc = mx.Exit
+		defer func() { exitFunc = os.Exit }()
+		wf.args = []string{*it.autocomplete}
+		wf.Args()
+		assert.Equal(t, []string{"open", helpURL}, me.args, "help not opened")
+		assert.Equal(t, 0, mx.code, "magic action did not exit")
+	})
+}
+
+// AutoWarnEmpty sends a warning only if there are no items.
+func TestAutoWarnEmpty(t *testing.T) {
+	tests := []struct {
+		opts  []Option
+		items []string
+		x     []string
+	}{
+		{nil, nil, []string{}},
+		{nil, []string{"item"}, []string{"item"}},
+		{[]Option{AutoWarnEmpty("No Results", "Try again")}, nil, []string{"No Results"}},
+		{[]Option{AutoWarnEmpty("No Results", "Try again")}, []string{"item"}, []string{"item"}},
+		{[]Option{AutoWarnEmpty("No Results", ""), AutoWarnEmpty("", "")}, nil, []string{}},
+	}
+
+	for i, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			withTestEnv(func(e MapEnv) {
+				wf := NewFromEnv(e, td.opts...)
+				wf.AddItems(td.items...)
+				out := captureStdout(func() { wf.SendFeedback() })
+
+				var fb struct {
+					Items []struct {
+						Title    string `json:"title"`
+						Subtitle string `json:"subtitle"`
+					} `json:"items"`
+				}
+				require.Nil(t, json.Unmarshal([]byte(out), &fb), "unmarshal feedback")
+				titles := []string{}
+				for _, it := range fb.Items {
+					titles = append(titles, it.Title)
+				}
+				assert.Equal(t, td.x, titles, "unexpected items")
+				if len(td.items) == 0 && len(td.x) > 0 {
+					assert.Equal(t, "Try again", fb.Items[0].Subtitle, "unexpected subtitle")
+				}
+			})
+		})
+	}
+}
+
+// Feedback can be sent again after a reset.
+func TestResetFeedback(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		wf.NewItem("first")
+		out := captureStdout(func() { wf.SendFeedback() })
+		assert.Contains(t, out, `"first"`, "feedback not sent")
+
+		wf.ResetFeedback()
+		assert.True(t, wf.IsEmpty(), "feedback not empty after reset")
+
+		wf.NewItem("second")
+		out = captureStdout(func() { wf.SendFeedback() })
+		assert.Contains(t, out, `"second"`, "feedback not sent after reset")
+		assert.NotContains(t, out, `"first"`, "old items sent after reset")
+	})
+}
+
+// Errors aren't sent if output has already been sent.
+func TestFatal_afterSend(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		me := &mockExit{}
+		exitFunc = me.Exit
+		defer func() { exitFunc = os.Exit }()
+
+		wf.Configure(TextErrors(true))
+		wf.NewItem("item")
+		out := captureStdout(func() { wf.SendFeedback() })
+		assert.Contains(t, out, `"item"`, "feedback not sent")
+
+		out = captureStdout(func() { wf.Fatal("error") })
+		assert.Equal(t, "", out, "error sent after feedback")
+		assert.Equal(t, 1, me.code, "workflow did not exit")
+	})
+}
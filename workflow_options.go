@@ -3,7 +3,21 @@
 
 package aw
 
-import "go.deanishe.net/fuzzy"
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.deanishe.net/fuzzy"
+
+	"github.com/deanishe/awgo/util/build"
+)
 
 // Option is a configuration option for Workflow.
 // Pass one or more Options to New() or Workflow.Configure().
@@ -65,6 +79,9 @@ func LogPrefix(prefix string) Option {
 // If a user enters this prefix, AwGo takes control of the workflow and
 // shows a list of matching magic commands to the user.
 //
+// Users can override the prefix by setting the workflow variable
+// aw_magic_prefix (EnvVarMagicPrefix).
+//
 // Default: workflow:
 func MagicPrefix(prefix string) Option {
 	return func(wf *Workflow) Option {
@@ -84,7 +101,54 @@ func MaxLogSize(bytes int) Option {
 	}
 }
 
+// LogWriter adds w as a destination for the workflow's log, in addition
+// to the log file and STDERR, e.g. to capture the log in tests.
+func LogWriter(w io.Writer) Option {
+	return func(wf *Workflow) Option {
+		writers := append(append([]io.Writer{}, wf.logWriters...), w)
+		return setLogWriters(writers, wf.logReplace)(wf)
+	}
+}
+
+// ReplaceLogWriter sends the workflow's log to w instead of to the log
+// file and STDERR.
+func ReplaceLogWriter(w io.Writer) Option {
+	return setLogWriters([]io.Writer{w}, true)
+}
+
+// setLogWriters sets the additional log destinations, and whether they
+// replace the default ones.
+func setLogWriters(writers []io.Writer, replace bool) Option {
+	return func(wf *Workflow) Option {
+		prevWriters, prevReplace := wf.logWriters, wf.logReplace
+		wf.logWriters, wf.logReplace = writers, replace
+		// already initialised, i.e. not called from New
+		if logInitialized {
+			wf.setLogOutput()
+		}
+		return setLogWriters(prevWriters, prevReplace)
+	}
+}
+
+// MaxLogSizeString sets the size when workflow log is rotated from a
+// human-readable string, e.g. "2MiB" or "512KiB". Supported units are
+// B, KiB, MiB and GiB; a number without a unit is a size in bytes.
+//
+// If s cannot be parsed, a warning is logged and the current size is kept.
+func MaxLogSizeString(s string) Option {
+	return func(wf *Workflow) Option {
+		n, err := parseSize(s)
+		if err != nil {
+			log.Printf("[warning] invalid log size %q: %v", s, err)
+			return MaxLogSize(wf.maxLogSize)
+		}
+		return MaxLogSize(n)(wf)
+	}
+}
+
 // MaxResults is the maximum number of results to send to Alfred.
+// SendFeedback drops any further Items, so sort or filter them first
+// to keep the best matches.
 // 0 means send all results.
 // Default: 0
 func MaxResults(num int) Option {
@@ -95,6 +159,40 @@ func MaxResults(num int) Option {
 	}
 }
 
+// AutoWarnEmpty tells SendFeedback to send a warning with the given title
+// and subtitle if there are no items, so you needn't call WarnEmpty before
+// every call to SendFeedback. Top-level variables are sent as normal.
+// An empty title turns the warning off.
+// Default: off
+func AutoWarnEmpty(title, subtitle string) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.warnEmpty
+		wf.warnEmpty = [2]string{title, subtitle}
+		return AutoWarnEmpty(prev[0], prev[1])
+	}
+}
+
+// BeforeSend adds a function that SendFeedback calls with Workflow's
+// Feedback immediately before sending it to Alfred, e.g. to set a variable
+// on every result in one place. Functions are called in the order they were
+// added, after MaxResults has been applied.
+func BeforeSend(fn func(fb *Feedback)) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.beforeSend
+		wf.beforeSend = append(append([]func(fb *Feedback){}, prev...), fn)
+		return setBeforeSend(prev)
+	}
+}
+
+// setBeforeSend replaces Workflow's BeforeSend functions.
+func setBeforeSend(funcs []func(fb *Feedback)) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.beforeSend
+		wf.beforeSend = funcs
+		return setBeforeSend(prev)
+	}
+}
+
 // TextErrors tells Workflow to print errors as text, not JSON.
 // Messages are still sent to STDOUT. Set to true if error
 // should be captured by Alfred, e.g. if output goes to a Notification.
@@ -106,6 +204,133 @@ func TextErrors(on bool) Option {
 	}
 }
 
+// DontRescuePanics tells Workflow.Run not to recover from panics, so
+// they propagate to the caller with their original stack trace instead of
+// being shown in Alfred and terminating the workflow. This is useful in
+// tests. By default, Run rescues panics.
+func DontRescuePanics(on bool) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.noRescue
+		wf.noRescue = on
+		return DontRescuePanics(prev)
+	}
+}
+
+// RunTimeout sets the maximum time Workflow.Run waits for your workflow
+// function to return. If it takes longer, Run shows a "Timed out" error in
+// Alfred and terminates the workflow, so a hung Script Filter doesn't leave
+// Alfred waiting. Any feedback added by the function is discarded.
+// Default: 0 (no timeout)
+func RunTimeout(d time.Duration) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.runTimeout
+		wf.runTimeout = d
+		return RunTimeout(prev)
+	}
+}
+
+// CompactOutput tells Workflow to send minified JSON to Alfred instead of
+// indented JSON, which is smaller and faster for Alfred to parse when there
+// are many results. Output is still indented when Alfred's debugger is
+// open, so it remains readable.
+func CompactOutput(on bool) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.compact
+		wf.compact = on
+		return CompactOutput(prev)
+	}
+}
+
+// CacheDirOption sets the workflow's cache directory, overriding the
+// directory specified by Alfred's alfred_workflow_cache variable.
+// The directory is created if it doesn't exist.
+func CacheDirOption(dir string) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.cacheDir
+		wf.cacheDir = dir
+		// already initialised, i.e. not called from New
+		if wf.Cache != nil {
+			wf.Cache = NewCache(wf.CacheDir())
+			wf.Session = NewSession(wf.CacheDir(), wf.SessionID())
+		}
+		return CacheDirOption(prev)
+	}
+}
+
+// DataDirOption sets the workflow's data directory, overriding the
+// directory specified by Alfred's alfred_workflow_data variable.
+// The directory is created if it doesn't exist.
+func DataDirOption(dir string) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.dataDir
+		wf.dataDir = dir
+		// already initialised, i.e. not called from New
+		if wf.Data != nil {
+			wf.Data = NewCache(wf.DataDir())
+		}
+		return DataDirOption(prev)
+	}
+}
+
+// ConfigFile reads default settings from JSON file filename in the
+// workflow's data directory (see NewJSONFileEnv). Alfred's variables
+// override the file's values, which fill in any variables that aren't set.
+// If the file doesn't exist, the workflow's configuration is unchanged.
+func ConfigFile(filename string) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.Config
+		dir := wf.dataDir
+		if dir == "" {
+			dir = wf.Config.Get(EnvVarDataDir)
+		}
+
+		e, err := NewJSONFileEnv(filepath.Join(dir, filename), wf.Config.Env)
+		if err != nil {
+			if !errors.Is(err, os.ErrNotExist) {
+				log.Printf("[warning] load config file: %v", err)
+			}
+		} else {
+			wf.Config = NewConfig(e)
+		}
+		return setConfig(prev)
+	}
+}
+
+// InfoPlistDefaults loads the workflow variables defined in info.plist
+// and uses their values as defaults for any variables missing from the
+// environment. When run from Alfred, all these variables are already set,
+// but this allows the workflow to see the same configuration when run
+// outside Alfred, e.g. from a terminal or in tests.
+//
+// If path is empty, the info.plist in the workflow's directory is used.
+// If info.plist can't be read, a warning is logged and Config is unchanged.
+func InfoPlistDefaults(path string) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.Config
+		p := path
+		if p == "" {
+			p = filepath.Join(wf.Dir(), "info.plist")
+		}
+
+		vars, err := build.Variables(p)
+		if err != nil {
+			log.Printf("[warning] read variables from info.plist: %v", err)
+		} else {
+			wf.Config = NewConfig(layeredEnv{wf.Config.Env, MapEnv(vars)})
+		}
+		return setConfig(prev)
+	}
+}
+
+// setConfig replaces the workflow's Config.
+func setConfig(cfg *Config) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.Config
+		wf.Config = cfg
+		return setConfig(prev)
+	}
+}
+
 // SortOptions sets the fuzzy sorting options for Workflow.Filter().
 // See fuzzy and fuzzy.Option for info on (configuring) the sorting
 // algorithm.
@@ -151,17 +376,56 @@ func SuppressUIDs(on bool) Option {
 	}
 }
 
+// DefaultValid makes feedback Items valid when they are created, so you
+// only need to call Item.Invalid() for the exceptions. Warning and error
+// items are always invalid.
+//
+// Modifiers have no default of their own: unless set with
+// Modifier.Valid, they inherit their Item's valid status.
+//
+// This setting only applies to Items created *after* it has been
+// set.
+func DefaultValid(on bool) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.Feedback.DefaultValid
+		wf.Feedback.DefaultValid = on
+		return DefaultValid(prev)
+	}
+}
+
+// SubtitleWeight includes Item subtitles in fuzzy filtering. Title
+// matches always rank above subtitle-only matches, whose scores are
+// multiplied by weight. 0 (the default) disables subtitle matching.
+//
+// See Feedback.SubtitleWeight.
+func SubtitleWeight(weight float64) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.Feedback.SubtitleWeight
+		wf.Feedback.SubtitleWeight = weight
+		return SubtitleWeight(prev)
+	}
+}
+
+// SpaceSeparatedTerms tells Workflow.Filter to match each whitespace-separated
+// word of the query independently, so "thrones game" matches "Game of Thrones".
+//
+// See Feedback.SpaceSeparatedTerms.
+func SpaceSeparatedTerms(on bool) Option {
+	return func(wf *Workflow) Option {
+		prev := wf.Feedback.SpaceSeparatedTerms
+		wf.Feedback.SpaceSeparatedTerms = on
+		return SpaceSeparatedTerms(prev)
+	}
+}
+
 // Update sets the updater for the Workflow.
 // Panics if a version number isn't set (in Alfred Preferences).
 //
 // See Updater interface and subpackage update for more documentation.
 func Update(updater Updater) Option {
 	return func(wf *Workflow) Option {
-		if updater != nil && wf.Version() == "" {
-			panic("can't set Updater as workflow has no version number")
-		}
 		prev := wf.Updater
-		wf.setUpdater(updater)
+		wf.SetUpdater(updater)
 		return Update(prev)
 	}
 }
@@ -187,3 +451,38 @@ func RemoveMagic(actions ...MagicAction) Option {
 		return AddMagic(actions...)
 	}
 }
+
+// size units understood by parseSize
+var sizeUnits = []struct {
+	suffix string
+	n      int
+}{
+	{"gib", 1 << 30},
+	{"mib", 1 << 20},
+	{"kib", 1 << 10},
+	{"b", 1},
+}
+
+// parseSize converts a human-readable size, e.g. "2MiB", into bytes.
+func parseSize(s string) (int, error) {
+	var (
+		str  = strings.ToLower(strings.TrimSpace(s))
+		mult = 1
+	)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(str, u.suffix) {
+			str = strings.TrimSpace(strings.TrimSuffix(str, u.suffix))
+			mult = u.n
+			break
+		}
+	}
+
+	f, err := strconv.ParseFloat(str, 64)
+	if err != nil {
+		return 0, fmt.Errorf("parse size: %w", err)
+	}
+	if f <= 0 {
+		return 0, fmt.Errorf("size must be positive: %s", s)
+	}
+	return int(f * float64(mult)), nil
+}
@@ -6,14 +6,22 @@ package aw
 import (
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 
 	"github.com/deanishe/awgo/util"
+	"github.com/deanishe/awgo/util/build"
 )
 
+// legacyDataDir returns the workflow's data directory under Alfred 3.
+var legacyDataDir = func(bundleID string) string {
+	return filepath.Join(build.AlfredDataDir(3), bundleID)
+}
+
 // Dir returns the path to the workflow's root directory.
 func (wf *Workflow) Dir() string {
 	wd, err := os.Getwd()
@@ -28,6 +36,12 @@ func (wf *Workflow) Dir() string {
 	return wf.dir
 }
 
+// OpenDir opens the workflow's directory in the default application
+// (usually Finder).
+func (wf *Workflow) OpenDir() error {
+	return wf.execFunc("open", wf.Dir())
+}
+
 // CacheDir returns the path to the workflow's cache directory.
 func (wf *Workflow) CacheDir() string {
 	if wf.cacheDir == "" {
@@ -37,6 +51,10 @@ func (wf *Workflow) CacheDir() string {
 	return wf.cacheDir
 }
 
+// CacheSize returns the total size in bytes of the workflow's cache
+// directory, including AwGo's own data. See Cache.Size().
+func (wf *Workflow) CacheSize() (int64, error) { return wf.Cache.Size() }
+
 // OpenCache opens the workflow's cache directory in the default application (usually Finder).
 func (wf *Workflow) OpenCache() error {
 	return wf.execFunc("open", wf.CacheDir())
@@ -56,11 +74,84 @@ func (wf *Workflow) DataDir() string {
 	return wf.dataDir
 }
 
+// MigrateData copies the workflow's data from its Alfred 3 data directory
+// if the current data directory is empty, e.g. because the user has
+// upgraded to Alfred 4. Existing data is never overwritten, so it is
+// safe to call MigrateData every time the workflow runs.
+func (wf *Workflow) MigrateData() error {
+	var (
+		dir = wf.DataDir()
+		old = legacyDataDir(wf.BundleID())
+	)
+	if old == dir || !util.PathExists(old) {
+		return nil
+	}
+
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, fi := range infos {
+		// ignore AwGo's own data
+		if fi.Name() != "_aw" {
+			return nil
+		}
+	}
+
+	log.Printf("migrating data from %q ...", util.PrettyPath(old))
+	return copyDir(old, dir)
+}
+
+// copyDir recursively copies the contents of src to dst. Existing files
+// are not overwritten.
+func copyDir(src, dst string) error {
+	return filepath.Walk(src, func(p string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if fi.IsDir() {
+			return os.MkdirAll(target, fi.Mode().Perm()|0700)
+		}
+		if !fi.Mode().IsRegular() || util.PathExists(target) {
+			return nil
+		}
+		return copyFile(p, target, fi.Mode().Perm())
+	})
+}
+
+// copyFile copies file src to dst.
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
 // OpenData opens the workflow's data directory in the default application (usually Finder).
 func (wf *Workflow) OpenData() error {
 	return wf.execFunc("open", wf.DataDir())
 }
 
+// DataSize returns the total size in bytes of the workflow's data
+// directory. See Cache.Size().
+func (wf *Workflow) DataSize() (int64, error) { return wf.Data.Size() }
+
 // ClearData deletes all files from the workflow's data directory.
 func (wf *Workflow) ClearData() error {
 	return util.ClearDirectory(wf.DataDir())
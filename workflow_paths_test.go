@@ -4,13 +4,56 @@
 package aw
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/deanishe/awgo/util"
 )
 
+// Cache and data directories can be set via Options.
+func TestDirOptions(t *testing.T) {
+	withTempDir(func(dir string) {
+		var (
+			cacheDir = filepath.Join(dir, "cache")
+			dataDir  = filepath.Join(dir, "data")
+			e        = MapEnv{EnvVarBundleID: tBundleID}
+		)
+
+		// cache and data variables aren't required
+		wf := NewFromEnv(e, CacheDirOption(cacheDir), DataDirOption(dataDir))
+		assert.Equal(t, cacheDir, wf.CacheDir(), "unexpected cache dir")
+		assert.Equal(t, cacheDir, wf.Cache.Dir, "unexpected Cache.Dir")
+		assert.Equal(t, dataDir, wf.DataDir(), "unexpected data dir")
+		assert.Equal(t, dataDir, wf.Data.Dir, "unexpected Data.Dir")
+		assert.DirExists(t, cacheDir, "cache dir not created")
+		assert.DirExists(t, dataDir, "data dir not created")
+
+		// change after initialisation
+		otherDir := filepath.Join(dir, "other")
+		prev := wf.Configure(CacheDirOption(otherDir))
+		assert.Equal(t, otherDir, wf.Cache.Dir, "unexpected Cache.Dir")
+		require.Nil(t, wf.Session.Store("test", []byte("test")), "session store failed")
+		assert.True(t, wf.Cache.Exists(wf.Session.name("test")), "session not in cache dir")
+
+		wf.Configure(prev)
+		assert.Equal(t, cacheDir, wf.Cache.Dir, "unexpected Cache.Dir")
+
+		// variables required without options
+		me := &mockExit{}
+		exitFunc = me.Exit
+		defer func() { exitFunc = os.Exit }()
+		out := captureStdout(func() {
+			assert.Panics(t, func() { NewFromEnv(e, CacheDirOption(cacheDir)) }, "missing data dir accepted")
+		})
+		assert.Contains(t, out, EnvVarDataDir+" is not set", "missing data dir not reported")
+	})
+}
+
 func TestReset(t *testing.T) {
 	withTestWf(func(wf *Workflow) {
 		s := wf.Dir()
@@ -39,6 +82,22 @@ func TestReset(t *testing.T) {
 	})
 }
 
+// CacheSize and DataSize report the size of the respective directories.
+func TestWorkflow_Sizes(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		require.Nil(t, wf.Cache.Store("cached", make([]byte, 100)), "store cache")
+		require.Nil(t, wf.Data.Store("data", make([]byte, 42)), "store data")
+
+		n, err := wf.CacheSize()
+		require.Nil(t, err, "cache size")
+		assert.Equal(t, int64(100), n, "unexpected cache size")
+
+		n, err = wf.DataSize()
+		require.Nil(t, err, "data size")
+		assert.Equal(t, int64(42), n, "unexpected data size")
+	})
+}
+
 func TestWorkflowRoot(t *testing.T) {
 	withTestWf(func(wf *Workflow) {
 		wd, err := os.Getwd()
@@ -71,6 +130,9 @@ func TestOpen(t *testing.T) {
 			{wf.OpenData, "open",
 				[]string{"open", wf.DataDir()},
 			},
+			{wf.OpenDir, "open",
+				[]string{"open", wf.Dir()},
+			},
 		}
 
 		for _, td := range tests {
@@ -82,3 +144,45 @@ func TestOpen(t *testing.T) {
 		}
 	})
 }
+
+// Data are copied from Alfred 3 data directory once.
+func TestMigrateData(t *testing.T) {
+	orig := legacyDataDir
+	defer func() { legacyDataDir = orig }()
+
+	withTempDir(func(dir string) {
+		oldDir := filepath.Join(dir, "Alfred 3", "Workflow Data", tBundleID)
+		legacyDataDir = func(bundleID string) string {
+			assert.Equal(t, tBundleID, bundleID, "unexpected bundle ID")
+			return oldDir
+		}
+
+		withTestWf(func(wf *Workflow) {
+			// nothing to migrate
+			require.Nil(t, wf.MigrateData(), "migrate data failed")
+
+			util.MustExist(filepath.Join(oldDir, "sub"))
+			require.Nil(t, ioutil.WriteFile(filepath.Join(oldDir, "settings.json"), []byte("old"), 0600), "write file failed")
+			require.Nil(t, ioutil.WriteFile(filepath.Join(oldDir, "sub", "data.txt"), []byte("data"), 0600), "write file failed")
+			// AwGo's own data don't count
+			util.MustExist(filepath.Join(wf.DataDir(), "_aw"))
+
+			require.Nil(t, wf.MigrateData(), "migrate data failed")
+			data, err := ioutil.ReadFile(filepath.Join(wf.DataDir(), "settings.json"))
+			require.Nil(t, err, "settings not migrated")
+			assert.Equal(t, "old", string(data), "unexpected settings")
+			data, err = ioutil.ReadFile(filepath.Join(wf.DataDir(), "sub", "data.txt"))
+			require.Nil(t, err, "nested file not migrated")
+			assert.Equal(t, "data", string(data), "unexpected data")
+
+			// only migrated once
+			require.Nil(t, ioutil.WriteFile(filepath.Join(wf.DataDir(), "settings.json"), []byte("new"), 0600), "write file failed")
+			require.Nil(t, ioutil.WriteFile(filepath.Join(oldDir, "other.txt"), []byte("other"), 0600), "write file failed")
+			require.Nil(t, wf.MigrateData(), "migrate data failed")
+			data, err = ioutil.ReadFile(filepath.Join(wf.DataDir(), "settings.json"))
+			require.Nil(t, err, "read settings failed")
+			assert.Equal(t, "new", string(data), "settings overwritten")
+			assert.False(t, util.PathExists(filepath.Join(wf.DataDir(), "other.txt")), "data migrated twice")
+		})
+	})
+}
@@ -4,6 +4,7 @@
 package aw
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,10 +13,10 @@ import (
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
-	"go.deanishe.net/env"
 
 	"github.com/deanishe/awgo/util"
 )
@@ -32,7 +33,62 @@ func TestWorkflowValues(t *testing.T) {
 
 // TestInvalidEnv executes workflow in an invalid environment.
 func TestInvalidEnv(t *testing.T) {
-	assert.Panics(t, func() { NewFromEnv(env.MapEnv{}) })
+	me := &mockExit{}
+	exitFunc = me.Exit
+	defer func() { exitFunc = os.Exit }()
+
+	tests := []struct {
+		env     MapEnv
+		opts    []Option
+		x, notX string
+	}{
+		{MapEnv{}, nil, EnvVarBundleID + " is not set: set a Bundle Id", ""},
+		{MapEnv{EnvVarBundleID: tBundleID}, nil, EnvVarCacheDir + " is not set", EnvVarBundleID},
+		{MapEnv{EnvVarBundleID: tBundleID, EnvVarCacheDir: "/tmp"}, nil, EnvVarDataDir + " is not set", EnvVarCacheDir},
+		{MapEnv{EnvVarBundleID: tBundleID}, []Option{CacheDirOption("/tmp")}, EnvVarDataDir + " is not set", EnvVarCacheDir},
+		{MapEnv{EnvVarBundleID: tBundleID, EnvVarCacheDir: "/dev/null/cache", EnvVarDataDir: "/tmp"}, nil,
+			`can't create directory \"/dev/null/cache\"`, "is not set"},
+	}
+
+	for i, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			me.code = 0
+			out := captureStdout(func() {
+				assert.Panics(t, func() { NewFromEnv(td.env, td.opts...) }, "invalid env accepted")
+			})
+			assert.Equal(t, 1, me.code, "workflow did not exit")
+			assert.Contains(t, out, "Workflow is misconfigured", "no error item")
+			assert.Contains(t, out, td.x, "unexpected message")
+			if td.notX != "" {
+				assert.NotContains(t, out, td.notX+" is not set", "unexpected message")
+			}
+		})
+	}
+
+	// plaintext errors
+	out := captureStdout(func() {
+		assert.Panics(t, func() { NewFromEnv(MapEnv{}, TextErrors(true)) }, "invalid env accepted")
+	})
+	assert.True(t, strings.HasPrefix(out, EnvVarBundleID+" is not set"), "unexpected output: %s", out)
+}
+
+// CheckEnv also checks that directories are writable.
+func TestWorkflow_CheckEnv(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		assert.Nil(t, wf.CheckEnv(), "valid env failed")
+
+		require.Nil(t, os.Chmod(wf.DataDir(), 0500), "chmod failed")
+		defer func() { panicOnErr(os.Chmod(wf.DataDir(), 0700)) }()
+		if os.Geteuid() == 0 {
+			t.Skip("root can write to any directory")
+		}
+		err := wf.CheckEnv()
+		require.NotNil(t, err, "read-only directory accepted")
+		var e *EnvError
+		require.True(t, errors.As(err, &e), "not an EnvError")
+		assert.Contains(t, e.Issues[0], "isn't writable", "unexpected issue")
+	})
 }
 
 // Options correctly alter Workflow.
@@ -64,10 +120,22 @@ func TestNew(t *testing.T) {
 			SortOptions(),
 			func(wf *Workflow) bool { return wf.sortOptions == nil },
 			"Set SortOptions"},
+		{
+			DefaultValid(true),
+			func(wf *Workflow) bool { return wf.Feedback.DefaultValid == true },
+			"Set DefaultValid"},
 		{
 			SuppressUIDs(true),
 			func(wf *Workflow) bool { return wf.Feedback.NoUIDs == true },
 			"Set SuppressUIDs"},
+		{
+			SubtitleWeight(0.5),
+			func(wf *Workflow) bool { return wf.Feedback.SubtitleWeight == 0.5 },
+			"Set SubtitleWeight"},
+		{
+			SpaceSeparatedTerms(true),
+			func(wf *Workflow) bool { return wf.Feedback.SpaceSeparatedTerms == true },
+			"Set SpaceSeparatedTerms"},
 		{
 			MagicPrefix("aw:"),
 			func(wf *Workflow) bool { return wf.magicPrefix == "aw:" },
@@ -76,10 +144,26 @@ func TestNew(t *testing.T) {
 			MaxLogSize(2048),
 			func(wf *Workflow) bool { return wf.maxLogSize == 2048 },
 			"Set MaxLogSize"},
+		{
+			MaxLogSizeString("1MiB"),
+			func(wf *Workflow) bool { return wf.maxLogSize == 1048576 },
+			"Set MaxLogSizeString (MiB)"},
+		{
+			MaxLogSizeString("512KiB"),
+			func(wf *Workflow) bool { return wf.maxLogSize == 524288 },
+			"Set MaxLogSizeString (KiB)"},
+		{
+			MaxLogSizeString("lots"),
+			func(wf *Workflow) bool { return wf.maxLogSize == DefaultMaxLogSize },
+			"Set MaxLogSizeString (invalid)"},
 		{
 			TextErrors(true),
 			func(wf *Workflow) bool { return wf.textErrors == true },
 			"Set TextErrors"},
+		{
+			CompactOutput(true),
+			func(wf *Workflow) bool { return wf.compact == true },
+			"Set CompactOutput"},
 		{
 			AddMagic(&mockMA{}),
 			func(wf *Workflow) bool { return wf.magicActions.actions["test"] != nil },
@@ -121,6 +205,177 @@ func TestWorkflow_Run_Rescue(t *testing.T) {
 	})
 }
 
+// Panics propagate to caller if DontRescuePanics is set.
+func TestWorkflow_Run_DontRescuePanics(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		me := &mockExit{code: -1}
+		exitFunc = me.Exit
+		defer func() { exitFunc = os.Exit }()
+
+		wf.Configure(DontRescuePanics(true))
+		assert.PanicsWithValue(t, "aaaargh!", func() {
+			wf.Run(func() { panic("aaaargh!") })
+		}, "panic not propagated")
+		assert.Equal(t, -1, me.code, "exit called")
+	})
+}
+
+// Run shows an error if fn takes longer than RunTimeout.
+func TestWorkflow_Run_RunTimeout(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		me := &mockExit{}
+		exitFunc = me.Exit
+		defer func() { exitFunc = os.Exit }()
+
+		wf.Configure(RunTimeout(10 * time.Millisecond))
+		release := make(chan struct{})
+		defer close(release)
+		out := captureStdout(func() {
+			wf.Run(func() { <-release })
+		})
+		assert.Equal(t, 1, me.code, "workflow did not time out")
+		assert.Contains(t, out, "Timed out after 10ms", "no error item")
+	})
+
+	// fast function
+	withTestWf(func(wf *Workflow) {
+		me := &mockExit{}
+		exitFunc = me.Exit
+		defer func() { exitFunc = os.Exit }()
+
+		wf.Configure(RunTimeout(time.Second))
+		var called bool
+		wf.Run(func() { called = true })
+		assert.True(t, called, "run wasn't called")
+		assert.Equal(t, 0, me.code, "workflow timed out")
+	})
+
+	// panics are still rescued
+	withTestWf(func(wf *Workflow) {
+		me := &mockExit{}
+		exitFunc = me.Exit
+		defer func() { exitFunc = os.Exit }()
+
+		wf.Configure(RunTimeout(time.Second))
+		out := captureStdout(func() {
+			wf.Run(func() { panic("aaaargh!") })
+		})
+		assert.Equal(t, 1, me.code, "workflow did not catch panic")
+		assert.Contains(t, out, "aaaargh!", "no error item")
+	})
+}
+
+func panicInTimedRun() { panic("aaaargh!") }
+
+// The stack trace of a panic in fn is where it happened, not where it
+// was re-raised.
+func TestWorkflow_Run_RunTimeout_stack(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		me := &mockExit{}
+		exitFunc = me.Exit
+		defer func() { exitFunc = os.Exit }()
+
+		var buf bytes.Buffer
+		orig := log.Writer()
+		log.SetOutput(&buf)
+		defer log.SetOutput(orig)
+
+		wf.Configure(RunTimeout(time.Second))
+		captureStdout(func() { wf.Run(panicInTimedRun) })
+		assert.Equal(t, 1, me.code, "workflow did not catch panic")
+		assert.Contains(t, buf.String(), "panicInTimedRun", "original stack not logged")
+	})
+}
+
+// Feedback sent by fn at the deadline isn't mixed with the timeout error.
+func TestWorkflow_Run_RunTimeout_send(t *testing.T) {
+	for i := 0; i < 10; i++ {
+		withTestWf(func(wf *Workflow) {
+			me := &mockExit{}
+			exitFunc = me.Exit
+			defer func() { exitFunc = os.Exit }()
+
+			wf.Configure(RunTimeout(5 * time.Millisecond))
+			done := make(chan struct{})
+			out := captureStdout(func() {
+				wf.Run(func() {
+					defer close(done)
+					for j := 0; j < 100; j++ {
+						wf.NewItem(fmt.Sprintf("item %d", j))
+					}
+					time.Sleep(5 * time.Millisecond)
+					wf.SendFeedback()
+				})
+				<-done
+			})
+
+			var v struct {
+				Items []interface{} `json:"items"`
+			}
+			require.Nil(t, json.Unmarshal([]byte(out), &v), "invalid output: %s", out)
+		})
+	}
+}
+
+// ArgsParsed decodes Alfred's multi-arg JSON.
+func TestWorkflow_ArgsParsed(t *testing.T) {
+	tests := []struct {
+		in, x []string
+	}{
+		{[]string{}, []string{}},
+		{[]string{"one"}, []string{"one"}},
+		{[]string{"one", "two"}, []string{"one", "two"}},
+		{[]string{`["one","two"]`}, []string{"one", "two"}},
+		{[]string{` ["one", "two words"] `}, []string{"one", "two words"}},
+		{[]string{`[]`}, []string{}},
+		{[]string{`[1, 2]`}, []string{`[1, 2]`}},
+		{[]string{`[not JSON`}, []string{`[not JSON`}},
+		{[]string{`["one"]`, "two"}, []string{`["one"]`, "two"}},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(fmt.Sprintf("%q", td.in), func(t *testing.T) {
+			withTestWf(func(wf *Workflow) {
+				wf.args = td.in
+				assert.Equal(t, td.x, wf.ArgsParsed(), "unexpected args")
+			})
+		})
+	}
+}
+
+// External Trigger variables are read from the environment.
+func TestWorkflow_Trigger(t *testing.T) {
+	withTestEnv(func(e MapEnv) {
+		wf := NewFromEnv(e)
+		assert.Equal(t, "", wf.Trigger(), "unexpected trigger")
+		assert.Equal(t, "", wf.TriggerArg(), "unexpected trigger arg")
+		assert.Equal(t, map[string]string{}, wf.TriggerVars(), "unexpected trigger vars")
+
+		e[EnvVarTrigger] = "search"
+		e[EnvVarTriggerArg] = "AwGo, yo!"
+		e[EnvVarTriggerVars] = "API_KEY,MISSING,MODE"
+		e["API_KEY"] = "hunter2"
+		e["MODE"] = ""
+		e["OTHER"] = "other"
+		wf = NewFromEnv(e)
+		assert.Equal(t, "search", wf.Trigger(), "unexpected trigger")
+		assert.Equal(t, "AwGo, yo!", wf.TriggerArg(), "unexpected trigger arg")
+		assert.Equal(t, map[string]string{"API_KEY": "hunter2", "MODE": ""}, wf.TriggerVars(), "unexpected trigger vars")
+	})
+}
+
+// Description is read from info.plist.
+func TestWorkflow_Description(t *testing.T) {
+	withTestWf(func(wf *Workflow) {
+		wf.dir = "testdata"
+		assert.Equal(t, "AwGo sample info.plist", wf.Description(), "unexpected description")
+
+		wf.dir = "testdata/subdir"
+		assert.Equal(t, "", wf.Description(), "unexpected description")
+	})
+}
+
 // TestWorkflowDir verifies that AwGo finds the right directory.
 func TestWorkflow_Dir(t *testing.T) {
 	t.Parallel()
@@ -214,6 +469,45 @@ func TestWorkflow_Rerun(t *testing.T) {
 	})
 }
 
+// Log output is sent to additional writers.
+func TestLogWriter(t *testing.T) {
+	orig := log.Writer()
+	defer log.SetOutput(orig)
+
+	withTestEnv(func(e MapEnv) {
+		var extra, only bytes.Buffer
+		wf := NewFromEnv(e, LogWriter(&extra))
+		log.Print("added writer")
+		assert.Contains(t, extra.String(), "added writer", "log not written to writer")
+
+		prev := wf.Configure(ReplaceLogWriter(&only))
+		log.Print("replaced writer")
+		assert.Contains(t, only.String(), "replaced writer", "log not written to writer")
+		assert.NotContains(t, extra.String(), "replaced writer", "log written to replaced writer")
+
+		wf.Configure(prev)
+		log.Print("restored writer")
+		assert.Contains(t, extra.String(), "restored writer", "log not written to writer")
+		assert.NotContains(t, only.String(), "restored writer", "log written to removed writer")
+	})
+}
+
+// Rerun is only set while cache is expired.
+func TestWorkflow_RerunUntilFresh(t *testing.T) {
+	t.Parallel()
+
+	withTestWf(func(wf *Workflow) {
+		assert.True(t, wf.RerunUntilFresh(wf.Cache, "test.txt", time.Minute, 0.5), "missing cache is fresh")
+		assert.Equal(t, 0.5, wf.Feedback.rerun, "rerun not set")
+	})
+
+	withTestWf(func(wf *Workflow) {
+		require.Nil(t, wf.Cache.Store("test.txt", []byte("test")), "store cache failed")
+		assert.False(t, wf.RerunUntilFresh(wf.Cache, "test.txt", time.Minute, 0.5), "fresh cache expired")
+		assert.Equal(t, 0.0, wf.Feedback.rerun, "rerun set")
+	})
+}
+
 func TestWorkflow_Fatal(t *testing.T) {
 	var exit bool
 	exitFunc = func(code int) { exit = true }
@@ -235,6 +529,37 @@ func TestWorkflow_Fatal(t *testing.T) {
 	})
 }
 
+// Detect whether workflow is running in Alfred.
+func TestWorkflow_RunningInAlfred(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		unset bool // delete alfred_version instead of emptying it
+		value string
+		x     bool
+	}{
+		{"version set", false, "4.0.4", true},
+		{"version empty", false, "", false},
+		{"version unset", true, "", false},
+	}
+
+	for _, td := range tests {
+		td := td
+		t.Run(td.name, func(t *testing.T) {
+			t.Parallel()
+			withTestEnv(func(e MapEnv) {
+				e[EnvVarAlfredVersion] = td.value
+				if td.unset {
+					delete(e, EnvVarAlfredVersion)
+				}
+				wf := NewFromEnv(e)
+				assert.Equal(t, td.x, wf.RunningInAlfred(), "unexpected result")
+			})
+		})
+	}
+}
+
 func TestRunCommand(t *testing.T) {
 	t.Parallel()
 
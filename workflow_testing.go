@@ -0,0 +1,80 @@
+// Copyright (c) 2019 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence applies http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+)
+
+// testExit is the panic value used to stop a workflow run by RunForTest
+// when it tries to exit.
+type testExit int
+
+// RunForTest runs fn via Workflow.Run() with a Workflow created from env
+// and returns everything the workflow wrote to STDOUT, i.e. its Script
+// Filter JSON or Run Script output. Use it to write end-to-end tests for
+// your workflow's run function.
+//
+// args are returned by Workflow.Args() instead of the program's
+// command-line arguments. If env is nil, the system environment is used.
+//
+// An error is returned if env is invalid or the workflow exits with a
+// non-zero status, e.g. by calling Workflow.Fatal(). Any output written
+// before the workflow exited is still returned.
+//
+// RunForTest temporarily replaces os.Stdout, so it is not safe to call
+// concurrently.
+func RunForTest(fn func(wf *Workflow), args []string, env Env) (output []byte, err error) {
+	if env == nil {
+		env = sysEnv{}
+	}
+	if err := validateEnv(env); err != nil {
+		return nil, err
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	origStdout, origExit := os.Stdout, exitFunc
+	os.Stdout = w
+	exitFunc = func(code int) { panic(testExit(code)) }
+	defer func() { os.Stdout, exitFunc = origStdout, origExit }()
+
+	ch := make(chan []byte)
+	go func() {
+		data, _ := ioutil.ReadAll(r)
+		ch <- data
+	}()
+
+	func() {
+		defer func() {
+			if v := recover(); v != nil {
+				code, ok := v.(testExit)
+				if !ok {
+					panic(v)
+				}
+				if code != 0 {
+					err = fmt.Errorf("workflow exited with status %d", code)
+				}
+			}
+		}()
+
+		wf := NewFromEnv(env)
+		if args == nil {
+			args = []string{}
+		}
+		wf.args = args
+		wf.Run(func() { fn(wf) })
+	}()
+
+	if e := w.Close(); e != nil && err == nil {
+		err = e
+	}
+	output = <-ch
+	return output, err
+}
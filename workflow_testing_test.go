@@ -0,0 +1,81 @@
+// Copyright (c) 2019 Dean Jackson <deanishe@deanishe.net>
+// MIT Licence applies http://opensource.org/licenses/MIT
+
+package aw
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Output and exit status of run function are captured.
+func TestRunForTest(t *testing.T) {
+	withTestEnv(func(e MapEnv) {
+		dir, err := ioutil.TempDir("", "awgo-")
+		require.Nil(t, err, "create temp dir failed")
+		defer func() { panicOnErr(os.RemoveAll(dir)) }()
+		e[EnvVarCacheDir] = filepath.Join(dir, "cache")
+		e[EnvVarDataDir] = filepath.Join(dir, "data")
+
+		// arguments
+		out, err := RunForTest(func(wf *Workflow) {
+			for _, s := range wf.Args() {
+				wf.NewItem(s)
+			}
+			wf.SendFeedback()
+		}, []string{"one", "two"}, e)
+		require.Nil(t, err, "RunForTest failed")
+		assert.Contains(t, string(out), `"title": "one"`, "missing first arg")
+		assert.Contains(t, string(out), `"title": "two"`, "missing second arg")
+
+		// fatal error
+		out, err = RunForTest(func(wf *Workflow) {
+			wf.Fatal("oops")
+			t.Error("workflow did not exit")
+		}, nil, e)
+		assert.NotNil(t, err, "exit status not returned")
+		assert.Contains(t, string(out), `"title": "oops"`, "error not output")
+
+		// panic
+		out, err = RunForTest(func(wf *Workflow) { panic("aaargh!") }, nil, e)
+		assert.NotNil(t, err, "exit status not returned")
+		assert.Contains(t, string(out), `"title": "aaargh!"`, "panic not output")
+
+		// invalid environment
+		_, err = RunForTest(func(wf *Workflow) {}, nil, MapEnv{})
+		assert.NotNil(t, err, "invalid env accepted")
+	})
+}
+
+// Test a workflow's run function by passing it to RunForTest along with
+// the arguments and environment to run it with.
+func ExampleRunForTest() {
+	run := func(wf *Workflow) {
+		wf.NewItem("Hello, " + wf.Args()[0])
+		wf.SendFeedback()
+	}
+
+	out, err := RunForTest(run, []string{"World"}, nil)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(out))
+	// Output:
+	// {
+	//   "variables": {
+	//     "AW_SESSION_ID": "test-session-id"
+	//   },
+	//   "items": [
+	//     {
+	//       "title": "Hello, World",
+	//       "valid": false
+	//     }
+	//   ]
+	// }
+}
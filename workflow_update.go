@@ -20,10 +20,35 @@ type Updater interface {
 // --------------------------------------------------------------------
 // Updating
 
+// SetUpdater sets or replaces the workflow's Updater after construction,
+// e.g. once the repo to update from has been read from the workflow's
+// configuration. The "update" magic action is registered if it isn't
+// already present. Pass nil to remove the Updater.
+func (wf *Workflow) SetUpdater(u Updater) {
+	if u != nil && wf.Version() == "" {
+		panic("can't set Updater as workflow has no version number")
+	}
+	wf.setUpdater(u)
+}
+
+// CurrentUpdater returns the workflow's Updater or nil if none is set.
+func (wf *Workflow) CurrentUpdater() Updater { return wf.Updater }
+
 // setUpdater sets an updater for the workflow.
 func (wf *Workflow) setUpdater(u Updater) {
 	wf.Updater = u
-	wf.magicActions.register(&updateMA{wf.Updater})
+	ma := &updateMA{u}
+	if u == nil {
+		wf.magicActions.unregister(ma)
+		return
+	}
+
+	switch a := wf.magicActions.actions[ma.Keyword()].(type) {
+	case nil:
+		wf.magicActions.register(ma)
+	case *updateMA:
+		a.updater = u
+	}
 }
 
 // UpdateCheckDue returns true if an update is available.
@@ -83,3 +83,34 @@ func TestWorkflowUpdater(t *testing.T) {
 	assert.Nil(t, wf.InstallUpdate(), "InstallUpdate failed")
 	assert.True(t, u.installCalled, "installCalled not called")
 }
+
+// Updater set after construction is registered as the "update" magic action.
+func TestSetUpdater(t *testing.T) {
+	t.Parallel()
+
+	wf := New()
+	assert.Nil(t, wf.CurrentUpdater(), "unexpected Updater")
+	assert.Nil(t, wf.magicActions.actions["update"], "update magic action registered")
+
+	u := &mockUpdater{}
+	wf.SetUpdater(u)
+	assert.Equal(t, u, wf.CurrentUpdater(), "unexpected Updater")
+	assert.NotNil(t, wf.magicActions.actions["update"], "update magic action not registered")
+
+	// replacing Updater doesn't re-register action
+	ma := wf.magicActions.actions["update"]
+	u2 := &mockUpdater{}
+	wf.SetUpdater(u2)
+	assert.Equal(t, u2, wf.CurrentUpdater(), "unexpected Updater")
+	assert.True(t, ma == wf.magicActions.actions["update"], "update magic action replaced")
+
+	_, v := wf.magicActions.handleArgs([]string{"workflow:update"}, DefaultMagicPrefix)
+	assert.True(t, v, "update magic action not handled")
+	assert.True(t, u2.installCalled, "new Updater not called")
+	assert.False(t, u.installCalled, "old Updater called")
+
+	// remove Updater
+	wf.SetUpdater(nil)
+	assert.Nil(t, wf.CurrentUpdater(), "unexpected Updater")
+	assert.Nil(t, wf.magicActions.actions["update"], "update magic action registered")
+}